@@ -0,0 +1,269 @@
+package capture
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"vjgdienynas/collector"
+)
+
+// hexIDRegexp matches hashes the diary puts into URLs and onclick handlers. Session token and cookies are not relied
+// on to look like that, they are registered with AddSession.
+var hexIDRegexp = regexp.MustCompile(`\b[0-9a-f]{32}\b`)
+
+// tokenFormat is the placeholder of session tokens and other hex IDs.
+const tokenFormat = "a0a0a0a0a0a0a0a0a0a0a0a0%08x"
+
+// lessonIDRegexps match lesson IDs in the contexts diary uses them: lesson info command arguments and request URLs.
+var lessonIDRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(tomval_AjaxCmd\('\w+', '\w+', ')(\d+)(', this\))`),
+	regexp.MustCompile(`([?&]id=)(\d+)(\b)`),
+}
+
+// lithuanianEndings are nominative endings of names, cut off to match names in other cases, e.g. "Jonai" for "Jonas".
+var lithuanianEndings = []string{"ius", "ias", "as", "is", "ys", "us", "ė", "a"}
+
+// redactedFormFields are request form values replaced entirely.
+var redactedFormFields = []string{"login_u", "login_p"}
+
+// Anonymizer replaces personal data and secrets in recordings. Replacements are consistent: the same original value
+// is always replaced by the same placeholder across all exchanges, so the recording stays coherent when replayed.
+type Anonymizer struct {
+	names map[string]string
+	// nameParts match single words of names in any case, e.g. a first name in lesson notes
+	nameParts  []namePart
+	classes    []namePart
+	lessonIDs  map[string]string
+	hexIDs     map[string]string
+	cookieVals map[string]string
+	// secrets are values replaced wherever they appear, mapped to their placeholders
+	secrets map[string]string
+}
+
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{
+		names:      map[string]string{},
+		lessonIDs:  map[string]string{},
+		hexIDs:     map[string]string{},
+		cookieVals: map[string]string{},
+		secrets:    map[string]string{},
+	}
+}
+
+// AddName registers a person's name to be replaced. Names are also matched in HTML-escaped forms, as diary sends
+// e.g. "&scaron;" instead of "š".
+func (a *Anonymizer) AddName(name string, replacement string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return
+	}
+	for _, variant := range nameVariants(name) {
+		a.names[variant] = replacement
+	}
+}
+
+// AddPerson registers a person's name like AddName, and also each word of it on its own and in other grammatical
+// cases, as diary texts address people by first name or surname. Words are replaced by words of the replacement.
+func (a *Anonymizer) AddPerson(name string, replacement string) {
+	a.AddName(name, replacement)
+	words := strings.Fields(name)
+	replacements := strings.Fields(replacement)
+	if len(replacements) == 0 {
+		return
+	}
+	for i, word := range words {
+		stem := word
+		for _, ending := range lithuanianEndings {
+			if trimmed, ok := strings.CutSuffix(word, ending); ok && len([]rune(trimmed)) >= 3 {
+				stem = trimmed
+				break
+			}
+		}
+		for _, variant := range lo.Uniq(nameVariants(stem)) {
+			a.nameParts = append(a.nameParts, namePart{
+				re:          regexp.MustCompile(`(^|[^\p{L}&])` + regexp.QuoteMeta(variant) + `\p{L}{0,4}($|[^\p{L}])`),
+				replacement: replacements[min(i, len(replacements)-1)],
+			})
+		}
+	}
+	// longest stems first, so that a surname is not taken for a shorter first name
+	slices.SortStableFunc(a.nameParts, func(x, y namePart) int {
+		return len(y.re.String()) - len(x.re.String())
+	})
+}
+
+// AddClass registers a class name, e.g. "5d", to be replaced where it stands on its own.
+func (a *Anonymizer) AddClass(name string, replacement string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return
+	}
+	a.classes = append(a.classes, namePart{
+		re:          regexp.MustCompile(`(^|[^\p{L}\p{N}])` + regexp.QuoteMeta(name) + `($|[^\p{L}\p{N}])`),
+		replacement: replacement,
+	})
+}
+
+// AddSession registers diary session token and cookie values to be replaced wherever they appear, e.g. in URLs and
+// links of the pages, whatever they look like.
+func (a *Anonymizer) AddSession(s collector.UpstreamSession) {
+	if s.Token != "" {
+		a.secrets[s.Token] = placeholder(a.hexIDs, s.Token, tokenFormat)
+	}
+	for _, value := range s.Cookies {
+		if value != "" {
+			a.secrets[value] = placeholder(a.cookieVals, value, "cookie%d")
+		}
+	}
+}
+
+// AddCollected registers everything the collector knows of: its diary session, the student, their class and teachers
+// of given lessons. Their names are replaced wherever they appear, including marks table notes and lesson topics.
+func (a *Anonymizer) AddCollected(c *collector.Collector, lessons []*collector.LessonInfo) {
+	a.AddSession(c.Session())
+	a.AddPerson(c.StudentName, "Vardenis Pavardenis")
+	a.AddClass(c.ClassName, "1a")
+	var teachers []string
+	for _, l := range lessons {
+		for _, teacher := range strings.Split(l.Teacher, ",") {
+			if teacher = strings.TrimSpace(teacher); teacher != "" {
+				teachers = append(teachers, teacher)
+			}
+		}
+	}
+	for i, teacher := range lo.Uniq(teachers) {
+		a.AddPerson(teacher, fmt.Sprintf("Mokytojas%d Pavardenis%d", i+1, i+1))
+	}
+}
+
+type namePart struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// replace substitutes the match keeping characters around it. Matches share no boundary characters, so it runs twice
+// to catch adjacent ones, e.g. first name followed by surname.
+func (p namePart) replace(s string) string {
+	for range 2 {
+		s = p.re.ReplaceAllString(s, "${1}"+strings.ReplaceAll(p.replacement, "$", "$$")+"${2}")
+	}
+	return s
+}
+
+func nameVariants(name string) []string {
+	escaped := html.EscapeString(name)
+	entities := strings.NewReplacer("š", "&scaron;", "Š", "&Scaron;").Replace(escaped)
+	return []string{name, escaped, entities}
+}
+
+// Anonymize returns a copy of the recording with names, tokens, cookies, credentials and lesson IDs replaced.
+func (a *Anonymizer) Anonymize(r Recording) Recording {
+	result := Recording{}
+	for _, e := range r.Exchanges {
+		result.Exchanges = append(result.Exchanges, Exchange{
+			Method:      e.Method,
+			URL:         a.text(e.URL),
+			RequestBody: a.requestBody(e.RequestBody),
+			Status:      e.Status,
+			Header:      a.header(e.Header),
+			Body:        a.text(e.Body),
+		})
+	}
+	return result
+}
+
+func (a *Anonymizer) text(s string) string {
+	for _, secret := range longestFirst(a.secrets) {
+		s = strings.ReplaceAll(s, secret, a.secrets[secret])
+	}
+	// longest names first, so that a full name is replaced before any of its parts
+	for _, name := range longestFirst(a.names) {
+		s = strings.ReplaceAll(s, name, a.names[name])
+	}
+	for _, part := range a.nameParts {
+		s = part.replace(s)
+	}
+	for _, class := range a.classes {
+		s = class.replace(s)
+	}
+
+	s = hexIDRegexp.ReplaceAllStringFunc(s, func(id string) string {
+		return placeholder(a.hexIDs, id, tokenFormat)
+	})
+	for _, re := range lessonIDRegexps {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			m := re.FindStringSubmatch(match)
+			return m[1] + placeholder(a.lessonIDs, m[2], "9%05d") + m[3]
+		})
+	}
+	return s
+}
+
+func (a *Anonymizer) requestBody(body string) string {
+	form, err := url.ParseQuery(body)
+	if err != nil || body == "" {
+		return a.text(body)
+	}
+	for _, field := range redactedFormFields {
+		if form.Has(field) {
+			form.Set(field, "redacted")
+		}
+	}
+	return a.text(form.Encode())
+}
+
+func (a *Anonymizer) header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	result := http.Header{}
+	for name, values := range h {
+		for _, value := range values {
+			if name == "Set-Cookie" {
+				value = a.setCookie(value)
+			}
+			result.Add(name, a.text(value))
+		}
+	}
+	return result
+}
+
+// setCookie replaces cookie value, keeping the attributes.
+func (a *Anonymizer) setCookie(value string) string {
+	nameValue, attributes, _ := strings.Cut(value, ";")
+	name, cookieValue, ok := strings.Cut(nameValue, "=")
+	if !ok || cookieValue == "" {
+		return value
+	}
+	result := name + "=" + placeholder(a.cookieVals, cookieValue, "cookie%d")
+	if attributes != "" {
+		result += ";" + attributes
+	}
+	return result
+}
+
+// longestFirst returns keys of values sorted by length, longest first.
+func longestFirst(values map[string]string) []string {
+	keys := lo.Keys(values)
+	slices.SortFunc(keys, func(x, y string) int {
+		return len(y) - len(x)
+	})
+	return keys
+}
+
+// placeholder returns replacement for the value, allocating next sequential one for values not seen before.
+func placeholder(seen map[string]string, value string, format string) string {
+	if replacement, ok := seen[value]; ok {
+		return replacement
+	}
+	replacement := fmt.Sprintf(format, len(seen)+1)
+	seen[value] = replacement
+	return replacement
+}
@@ -0,0 +1,173 @@
+// Package capture records HTTP traffic of a collector session and anonymizes it, so that real diary responses can be
+// committed as test fixtures and replayed offline.
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Exchange is a single recorded request and its response.
+type Exchange struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"requestBody,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+type Recording struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// recordedHeaders are response headers needed for replay; the rest are dropped.
+var recordedHeaders = []string{"Content-Type", "Set-Cookie", "Location"}
+
+func ReadRecording(path string) (*Recording, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := Recording{}
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return nil, fmt.Errorf("parsing recording: %w", err)
+	}
+	return &result, nil
+}
+
+func (r Recording) Write(path string) error {
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, contents, 0644)
+}
+
+// Recorder is a transport that keeps every request/response pair passing through it.
+type Recorder struct {
+	transport http.RoundTripper
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{transport: transport}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := http.Header{}
+	for _, name := range recordedHeaders {
+		for _, value := range resp.Header.Values(name) {
+			header.Add(name, value)
+		}
+	}
+
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, Exchange{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(requestBody),
+		Status:      resp.StatusCode,
+		Header:      header,
+		Body:        string(body),
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Recording returns everything recorded so far.
+func (r *Recorder) Recording() Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Recording{Exchanges: slices.Clone(r.exchanges)}
+}
+
+// Replayer is a transport serving responses from a recording. Requests are matched by method, path and query, except
+// the "time" parameter that changes on every call; repeated requests get recorded responses in order, the last one
+// being repeated. Host is ignored, so recordings can be replayed against any base URL.
+type Replayer struct {
+	mu       sync.Mutex
+	byKey    map[string][]Exchange
+	servedBy map[string]int
+}
+
+func NewReplayer(recording Recording) *Replayer {
+	r := &Replayer{
+		byKey:    map[string][]Exchange{},
+		servedBy: map[string]int{},
+	}
+	for _, e := range recording.Exchanges {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		key := replayKey(e.Method, u)
+		r.byKey[key] = append(r.byKey[key], e)
+	}
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := replayKey(req.Method, req.URL)
+
+	r.mu.Lock()
+	exchanges := r.byKey[key]
+	index := min(r.servedBy[key], len(exchanges)-1)
+	r.servedBy[key]++
+	r.mu.Unlock()
+
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	e := exchanges[index]
+
+	return &http.Response{
+		Status:     http.StatusText(e.Status),
+		StatusCode: e.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     e.Header.Clone(),
+		Body:       io.NopCloser(strings.NewReader(e.Body)),
+		Request:    req,
+	}, nil
+}
+
+func replayKey(method string, u *url.URL) string {
+	query := u.Query()
+	query.Del("time")
+	return method + " " + u.Path + "?" + query.Encode()
+}
@@ -0,0 +1,95 @@
+package capture
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/collector"
+	"vjgdienynas/fakediary"
+)
+
+func TestRecordAnonymizeReplay(t *testing.T) {
+	r := require.New(t)
+	diary := fakediary.NewServer()
+	defer diary.Close()
+
+	recorder := NewRecorder(http.DefaultTransport)
+	c := collector.NewCollector(collector.WithBaseURL(diary.URL))
+	c.WithTransport(recorder)
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+	original, err := c.GetLessonInfos()
+	r.NoError(err)
+	token := c.Session().Token
+
+	a := NewAnonymizer()
+	a.AddCollected(c, original.Lessons)
+	anonymized := a.Anonymize(recorder.Recording())
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	r.NoError(anonymized.Write(path))
+	contents, err := ReadRecording(path)
+	r.NoError(err)
+	serialized, err := json.Marshal(contents)
+	r.NoError(err)
+	leaked := []string{
+		// header: student and class
+		fakediary.StudentName, fakediary.ClassName + " klasė",
+		// marks table notes: student addressed by first name
+		"Jonai",
+		// lesson details: teachers, also mentioned by surname in topics
+		"Petras Petraitis", "Ona Onait", "Onait", "Petrait",
+		fakediary.Password, token, "session1", "'1001'", "id=1001",
+	}
+	for _, leaked := range leaked {
+		r.NotContains(string(serialized), leaked)
+	}
+
+	// replay needs no server at all
+	diary.Close()
+	replayed := collector.NewCollector(collector.WithBaseURL("http://replay.invalid"))
+	replayed.WithTransport(NewReplayer(*contents))
+	r.NoError(replayed.Login("any", "any"))
+	r.Equal("Vardenis Pavardenis", replayed.StudentName)
+
+	infos, err := replayed.GetLessonInfos()
+	r.NoError(err)
+	r.Len(infos.Lessons, len(original.Lessons))
+	r.Len(infos.Failures, len(original.Failures))
+	for _, l := range infos.Lessons {
+		r.NotContains([]string{"1001", "1002", "2001", "2002"}, l.ID)
+	}
+	math, ok := lo.Find(infos.Lessons, func(item *collector.LessonInfo) bool {
+		return item.Topic == "Trupmenų sudėtis"
+	})
+	r.True(ok)
+	r.Contains([]string{"Mokytojas1 Pavardenis1", "Mokytojas2 Pavardenis2"}, math.Teacher)
+	r.Equal("Vardenis, puikiai dirbo pamokoje", math.LessonNotes.Note)
+	r.Equal("1a", replayed.ClassName)
+	r.Equal("9", math.Mark)
+}
+
+func TestAnonymizer_AddPerson(t *testing.T) {
+	a := NewAnonymizer()
+	a.AddPerson("Jonas Šimkus", "Vardenis Pavardenis")
+	a.AddClass("5d", "1a")
+
+	tests := map[string]string{
+		"Jonas Šimkus, 5d klasė":         "Vardenis Pavardenis, 1a klasė",
+		"Jonai, puikiai!":                "Vardenis, puikiai!",
+		"Šimkui pagyrimas":               "Pavardenis pagyrimas",
+		"&Scaron;imkaus darbas":          "Pavardenis darbas",
+		"Jonaitis, a5d3f0, 15d, 5da":     "Jonaitis, a5d3f0, 15d, 5da",
+		"Jonas Jonas":                    "Vardenis Vardenis",
+		"showhint('Jonai, dirbk', this)": "showhint('Vardenis, dirbk', this)",
+	}
+	for text, expected := range tests {
+		t.Run(text, func(t *testing.T) {
+			require.Equal(t, expected, a.text(text))
+		})
+	}
+}
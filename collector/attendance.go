@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/samber/lo"
+)
+
+// AttendanceKind tells whether student missed the lesson or came late.
+type AttendanceKind string
+
+const (
+	Absent AttendanceKind = "absent"
+	Late   AttendanceKind = "late"
+)
+
+// Attendance is an absence marker of a lesson in the marks table.
+type Attendance struct {
+	// Code is the marker as shown in the diary, e.g. "n"
+	Code      string         `json:"code"`
+	Kind      AttendanceKind `json:"kind"`
+	Justified bool           `json:"justified"`
+}
+
+// attendanceCodes are markers diary puts in the marks table instead of, or next to, a mark.
+var attendanceCodes = map[string]Attendance{
+	"n":  {Code: "n", Kind: Absent},
+	"nl": {Code: "nl", Kind: Absent, Justified: true},
+	"np": {Code: "np", Kind: Absent, Justified: true},
+	"p":  {Code: "p", Kind: Late},
+	"pp": {Code: "pp", Kind: Late, Justified: true},
+}
+
+// Absence is an attendance marker on a day without a lesson entry of the discipline, e.g. one teacher has not filled in
+// yet. It is counted in attendance, but is not a lesson.
+type Absence struct {
+	Discipline string
+	Day        *time.Time
+	Attendance Attendance
+}
+
+// parseMarkCell separates mark from attendance marker in a marks table cell. Markers come either as cell text or in
+// spans; other spans are hints, e.g. "*" for a commented mark.
+func parseMarkCell(cell *goquery.Selection) (string, *Attendance) {
+	var attendance *Attendance
+	cell.Find("span").Each(func(_ int, span *goquery.Selection) {
+		if a, ok := attendanceCodes[strings.ToLower(strings.TrimSpace(span.Text()))]; ok {
+			attendance = &a
+		}
+	})
+
+	text := cell.Clone()
+	text.Find("span").Remove()
+	var mark []string
+	for _, token := range strings.Fields(text.Text()) {
+		if a, ok := attendanceCodes[strings.ToLower(token)]; ok {
+			attendance = &a
+			continue
+		}
+		mark = append(mark, token)
+	}
+	return strings.Join(mark, " "), attendance
+}
+
+// assignAttendance gives absence markers of a day column to lessons of that day that have none yet. Markers left over
+// are returned as absences.
+func assignAttendance(lessons []*LessonInfo, markers []Attendance, discipline string, day *time.Time) []Absence {
+	var unlinked []Absence
+	for _, marker := range markers {
+		lesson, ok := lo.Find(lessons, func(item *LessonInfo) bool {
+			return item.Attendance == nil
+		})
+		if !ok {
+			unlinked = append(unlinked, Absence{Discipline: discipline, Day: day, Attendance: marker})
+			continue
+		}
+		lesson.Attendance = &marker
+	}
+	return unlinked
+}
+
+// AttendanceCounts counts lessons with absence markers. Justified and Unjustified split Absent; late arrivals are only
+// counted by Late.
+type AttendanceCounts struct {
+	Absent      int `json:"absent"`
+	Late        int `json:"late"`
+	Justified   int `json:"justified"`
+	Unjustified int `json:"unjustified"`
+}
+
+func (c *AttendanceCounts) add(a Attendance) {
+	switch a.Kind {
+	case Absent:
+		c.Absent++
+		if a.Justified {
+			c.Justified++
+		} else {
+			c.Unjustified++
+		}
+	case Late:
+		c.Late++
+	}
+}
+
+type DisciplineAttendance struct {
+	Discipline string `json:"discipline"`
+	AttendanceCounts
+}
+
+type WeekAttendance struct {
+	// Week is the Monday of the week, "2006-01-02"
+	Week string `json:"week"`
+	AttendanceCounts
+}
+
+// AttendanceSummary counts absences of a semester overall, by discipline and by week.
+type AttendanceSummary struct {
+	Total       AttendanceCounts       `json:"total"`
+	Disciplines []DisciplineAttendance `json:"disciplines"`
+	Weeks       []WeekAttendance       `json:"weeks"`
+}
+
+// SummarizeAttendance counts absence markers of lessons and absences without a lesson, as in LessonInfos. Disciplines
+// are sorted by name and weeks by date; only the ones with absences are listed.
+func SummarizeAttendance(lessons []*LessonInfo, absences []Absence) AttendanceSummary {
+	result := AttendanceSummary{
+		Disciplines: []DisciplineAttendance{},
+		Weeks:       []WeekAttendance{},
+	}
+	byDiscipline := map[string]*AttendanceCounts{}
+	byWeek := map[string]*AttendanceCounts{}
+	add := func(a Absence) {
+		result.Total.add(a.Attendance)
+
+		if byDiscipline[a.Discipline] == nil {
+			byDiscipline[a.Discipline] = &AttendanceCounts{}
+		}
+		byDiscipline[a.Discipline].add(a.Attendance)
+
+		if a.Day == nil {
+			return
+		}
+		week := weekStart(*a.Day).Format(time.DateOnly)
+		if byWeek[week] == nil {
+			byWeek[week] = &AttendanceCounts{}
+		}
+		byWeek[week].add(a.Attendance)
+	}
+	for _, l := range lessons {
+		if l.Attendance != nil {
+			add(Absence{Discipline: l.Discipline, Day: l.Day, Attendance: *l.Attendance})
+		}
+	}
+	for _, a := range absences {
+		add(a)
+	}
+
+	disciplines := lo.Keys(byDiscipline)
+	slices.Sort(disciplines)
+	for _, discipline := range disciplines {
+		result.Disciplines = append(result.Disciplines, DisciplineAttendance{Discipline: discipline, AttendanceCounts: *byDiscipline[discipline]})
+	}
+	weeks := lo.Keys(byWeek)
+	slices.Sort(weeks)
+	for _, week := range weeks {
+		result.Weeks = append(result.Weeks, WeekAttendance{Week: week, AttendanceCounts: *byWeek[week]})
+	}
+	return result
+}
+
+// weekStart returns the Monday of t's week.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
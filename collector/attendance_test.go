@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarkCell(t *testing.T) {
+	tests := map[string]struct {
+		cell               string
+		expectedMark       string
+		expectedAttendance *Attendance
+	}{
+		"mark":                 {cell: `9`, expectedMark: "9"},
+		"mark with hint":       {cell: `9<span class="marks_hint">*</span>`, expectedMark: "9"},
+		"empty":                {cell: ``},
+		"absent":               {cell: `n`, expectedAttendance: &Attendance{Code: "n", Kind: Absent}},
+		"ill":                  {cell: `<span class="marks_attendance">nl</span>`, expectedAttendance: &Attendance{Code: "nl", Kind: Absent, Justified: true}},
+		"late":                 {cell: `<span>P</span>`, expectedAttendance: &Attendance{Code: "p", Kind: Late}},
+		"late with a mark":     {cell: `10 p`, expectedMark: "10", expectedAttendance: &Attendance{Code: "p", Kind: Late}},
+		"credit is not absent": {cell: `įsk`, expectedMark: "įsk"},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tr><td id="cell">` + tt.cell + `</td></tr></table>`))
+			r.NoError(err)
+			cell := doc.Find("#cell")
+
+			mark, attendance := parseMarkCell(cell)
+			r.Equal(tt.expectedMark, mark)
+			r.Equal(tt.expectedAttendance, attendance)
+			r.Equal(1, cell.Length(), "cell should be left intact")
+		})
+	}
+}
+
+func TestSummarizeAttendance(t *testing.T) {
+	day := func(month time.Month, d int) *time.Time {
+		result := time.Date(2024, month, d, 8, 0, 0, 0, time.UTC)
+		return &result
+	}
+	lessons := []*LessonInfo{
+		{Discipline: "Matematika", Day: day(time.December, 16), Attendance: &Attendance{Code: "n", Kind: Absent}},
+		{Discipline: "Matematika", Day: day(time.December, 18), Mark: "9"},
+		{Discipline: "Fizika", Day: day(time.December, 22), Attendance: &Attendance{Code: "p", Kind: Late}},
+	}
+	absences := []Absence{
+		{Discipline: "Matematika", Day: day(time.December, 23), Attendance: Attendance{Code: "nl", Kind: Absent, Justified: true}},
+	}
+
+	require.Equal(t, AttendanceSummary{
+		Total: AttendanceCounts{Absent: 2, Late: 1, Justified: 1, Unjustified: 1},
+		Disciplines: []DisciplineAttendance{
+			{Discipline: "Fizika", AttendanceCounts: AttendanceCounts{Late: 1}},
+			{Discipline: "Matematika", AttendanceCounts: AttendanceCounts{Absent: 2, Justified: 1, Unjustified: 1}},
+		},
+		Weeks: []WeekAttendance{
+			{Week: "2024-12-16", AttendanceCounts: AttendanceCounts{Absent: 1, Late: 1, Unjustified: 1}},
+			{Week: "2024-12-23", AttendanceCounts: AttendanceCounts{Absent: 1, Justified: 1}},
+		},
+	}, SummarizeAttendance(lessons, absences))
+
+	require.Equal(t, AttendanceSummary{Disciplines: []DisciplineAttendance{}, Weeks: []WeekAttendance{}}, SummarizeAttendance(nil, nil))
+}
+
+func TestAssignAttendance(t *testing.T) {
+	r := require.New(t)
+	day := lo.ToPtr(time.Date(2025, time.January, 8, 8, 0, 0, 0, time.UTC))
+	absent := Attendance{Code: "n", Kind: Absent}
+	late := Attendance{Code: "p", Kind: Late}
+
+	first := &LessonInfo{ID: "1", Day: day}
+	second := &LessonInfo{ID: "2", Day: day, Attendance: &late}
+	r.Empty(assignAttendance([]*LessonInfo{second, first}, []Attendance{absent}, "Matematika", day))
+	r.Equal(&absent, first.Attendance, "marker goes to the lesson without one")
+	r.Equal(&late, second.Attendance)
+
+	// a marker in a cell with no lesson is an absence of its own
+	unlinked := assignAttendance(nil, []Attendance{absent, late}, "Matematika", day)
+	r.Equal([]Absence{
+		{Discipline: "Matematika", Day: day, Attendance: absent},
+		{Discipline: "Matematika", Day: day, Attendance: late},
+	}, unlinked)
+}
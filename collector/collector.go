@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
 	"slices"
@@ -9,37 +12,178 @@ import (
 	"strings"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
 	"github.com/samber/lo"
 )
 
-const remoteLocation = "https://dienynas.vjg.lt"
+// DefaultBaseURL is the address of the real diary.
+const DefaultBaseURL = "https://dienynas.vjg.lt"
+
+// ErrSessionExpired is returned when diary responds with a login form instead of requested page.
+var ErrSessionExpired = errors.New("diary session expired")
+
+// ErrLoginFailed is returned when diary does not accept the credentials, e.g. after the password was changed.
+var ErrLoginFailed = errors.New("could not login")
 
 type Collector struct {
 	c           *colly.Collector
+	baseURL     string
 	loginToken  string
 	StudentName string
+	// ClassName is student's class as shown in diary header, e.g. "5d"; empty if it could not be recognized
+	ClassName string
+
+	// credentials are kept to login again once diary session expires
+	user     string
+	password string
+
+	// semesters are cached from the marks page diary shows by default
+	semesters []Semester
+
+	fetch fetchPolicy
+	// ctx cancels diary requests, e.g. when the client of a server request goes away
+	ctx context.Context
 }
 
-func NewCollector() *Collector {
-	return &Collector{
+// requestTimeout limits every single request to the diary.
+const requestTimeout = 20 * time.Second
+
+type Option func(c *Collector)
+
+// WithBaseURL points collector to a different diary location, e.g. a local stand-in for tests.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Collector) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithContext cancels diary requests once ctx is done.
+func WithContext(ctx context.Context) Option {
+	return func(c *Collector) {
+		c.ctx = ctx
+	}
+}
+
+func NewCollector(opts ...Option) *Collector {
+	c := &Collector{
 		c: colly.NewCollector(
 			colly.MaxDepth(1),
+			colly.AllowURLRevisit(),
 		),
+		baseURL: DefaultBaseURL,
+		fetch:   defaultFetchPolicy,
+	}
+	for _, o := range opts {
+		o(c)
 	}
+	c.c.SetRequestTimeout(requestTimeout)
+	if c.ctx != nil {
+		c.WithTransport(http.DefaultTransport)
+	}
+	return c
+}
+
+// UpstreamSession is diary login state: the token passed in query strings and the session cookies.
+type UpstreamSession struct {
+	Token       string            `json:"token"`
+	StudentName string            `json:"studentName"`
+	ClassName   string            `json:"className,omitempty"`
+	Cookies     map[string]string `json:"cookies,omitempty"`
+}
+
+// Session returns current diary login state, to be later passed to RestoreCollector.
+func (c *Collector) Session() UpstreamSession {
+	result := UpstreamSession{
+		Token:       c.loginToken,
+		StudentName: c.StudentName,
+		ClassName:   c.ClassName,
+	}
+	for _, cookie := range c.c.Cookies(c.baseURL) {
+		if result.Cookies == nil {
+			result.Cookies = map[string]string{}
+		}
+		result.Cookies[cookie.Name] = cookie.Value
+	}
+	return result
+}
+
+// RestoreCollector creates a collector that continues a previous diary session instead of logging in. Credentials
+// are only used if that session turns out to be expired.
+func RestoreCollector(s UpstreamSession, user string, password string, opts ...Option) (*Collector, error) {
+	c := NewCollector(opts...)
+	c.loginToken = s.Token
+	c.StudentName = s.StudentName
+	c.ClassName = s.ClassName
+	c.user = user
+	c.password = password
+
+	cookies := lo.MapToSlice(s.Cookies, func(name string, value string) *http.Cookie {
+		return &http.Cookie{Name: name, Value: value}
+	})
+	if err := c.c.SetCookies(c.baseURL, cookies); err != nil {
+		return nil, fmt.Errorf("restoring cookies: %w", err)
+	}
+	return c, nil
+}
+
+// withSession runs a diary request, logging in again and retrying once if diary session has expired.
+func (c *Collector) withSession(fn func() error) error {
+	err := fn()
+	if !errors.Is(err, ErrSessionExpired) || c.user == "" {
+		return err
+	}
+
+	if err := c.Login(c.user, c.password); err != nil {
+		return fmt.Errorf("logging in again: %w", err)
+	}
+	return fn()
+}
+
+// detectLoginForm flags the response as expired session when diary serves login form instead of requested page.
+func detectLoginForm(cc *colly.Collector, expired *bool) {
+	cc.OnHTML("input[name='login_u']", func(_ *colly.HTMLElement) {
+		*expired = true
+	})
 }
 
 func (c *Collector) WithTransport(transport http.RoundTripper) {
+	if c.ctx != nil {
+		transport = contextTransport{ctx: c.ctx, next: transport}
+	}
 	c.c.WithTransport(transport)
 }
 
+// contextTransport makes requests with given context; colly does not take one.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(request.WithContext(t.ctx))
+}
+
 func (c *Collector) Login(user string, password string) error {
-	c.c.OnHTML("#top_bar > div.left.studentname > ul > li > table > tbody > tr:nth-child(1) > td:nth-child(2) > span:nth-child(1)", func(element *colly.HTMLElement) {
+	c.loginToken = ""
+	c.StudentName = ""
+	c.ClassName = ""
+
+	const studentInfoSelector = "#top_bar > div.left.studentname > ul > li > table > tbody > tr:nth-child(1) > td:nth-child(2)"
+	loginCollector := c.c.Clone()
+	loginCollector.OnHTML(studentInfoSelector+" > span:nth-child(1)", func(element *colly.HTMLElement) {
 		c.StudentName = element.Text
 	})
+	loginCollector.OnHTML(studentInfoSelector+" > span:not(:first-child)", func(element *colly.HTMLElement) {
+		if className := parseClassName(element.Text); className != "" {
+			c.ClassName = className
+		}
+	})
 
 	const tokenSelector = "a[href^='index.php?page=login&token=']"
-	c.c.OnHTML(tokenSelector, func(e *colly.HTMLElement) {
+	loginCollector.OnHTML(tokenSelector, func(e *colly.HTMLElement) {
 		href := e.Attr("href")
 
 		u, err := url.Parse(href)
@@ -47,10 +191,10 @@ func (c *Collector) Login(user string, password string) error {
 			return
 		}
 		c.loginToken = u.Query().Get("token")
-		c.c.OnHTMLDetach(tokenSelector)
+		loginCollector.OnHTMLDetach(tokenSelector)
 	})
 
-	err := c.c.Post(remoteLocation+"/index.php?page=login&lng=&token=", map[string]string{
+	err := loginCollector.Post(c.baseURL+"/index.php?page=login&lng=&token=", map[string]string{
 		"login_u": user,
 		"login_p": password,
 	})
@@ -58,115 +202,304 @@ func (c *Collector) Login(user string, password string) error {
 		return err
 	}
 	if c.loginToken == "" || c.StudentName == "" {
-		return fmt.Errorf("could not login")
+		return ErrLoginFailed
 	}
+	c.user = user
+	c.password = password
 
 	return nil
 }
 
-func (c *Collector) GetLessonInfos() ([]*LessonInfo, error) {
+// ErrUnknownSemester is returned when requested semester is not offered by the diary.
+var ErrUnknownSemester = errors.New("unknown semester")
+
+// ListSemesters returns semesters available in the marks page semester selector. The list is fetched once per
+// collector, or taken from a marks page visited before.
+func (c *Collector) ListSemesters() ([]Semester, error) {
+	if c.semesters != nil {
+		return c.semesters, nil
+	}
+	err := c.withSession(func() error {
+		page, err := c.visitMarksPage("", false)
+		if err != nil {
+			return err
+		}
+		c.semesters = parseSemesterOptions(page, nil)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.semesters, nil
+}
+
+// Disciplines returns names of disciplines listed in the marks table of the semester selected by WithSemester, current
+// one by default. Other options do not apply.
+func (c *Collector) Disciplines(opts ...LessonInfosOption) ([]string, error) {
+	options := lessonInfosOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	_, page, err := c.semesterPage(options.semesterID, false, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	page.Find(".marks_table .marks_tr_discrow .marks_td_discname").Each(func(_ int, element *goquery.Selection) {
+		result = append(result, strings.TrimSpace(element.Text()))
+	})
+	return lo.Uniq(result), nil
+}
+
+// visitMarksPage fetches marks page of given semester, or of the one diary selects by default when id is empty; final
+// selects final grades view.
+func (c *Collector) visitMarksPage(semesterID string, final bool) (*goquery.Selection, error) {
+	var page *goquery.Selection
+	expired := false
+
+	pageCollector := c.c.Clone()
+	detectLoginForm(pageCollector, &expired)
+	pageCollector.OnHTML("html", func(element *colly.HTMLElement) {
+		page = element.DOM
+	})
+
 	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
-	lessonsByID := map[string]*LessonInfo{}
+	pageURL := fmt.Sprintf(c.baseURL+"/marks.php?time=%d&token=%s", timestamp, c.loginToken)
+	if semesterID != "" {
+		pageURL += "&semester=" + url.QueryEscape(semesterID)
+	}
+	pageURL += fmt.Sprintf("&alldays=0&final=%d", lo.Ternary(final, 1, 0))
+	if err := pageCollector.Visit(pageURL); err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrSessionExpired
+	}
+	if page == nil {
+		return nil, fmt.Errorf("empty marks page")
+	}
+	return page, nil
+}
 
-	lessonInfoCollector := c.c.Clone()
-	lessonInfoCollector.Async = true
-	err := lessonInfoCollector.Limit(&colly.LimitRule{DomainGlob: "*", Parallelism: 10})
+// parseSemesterOptions reads semester selector of a marks page. Selected option is the current semester only on the
+// page diary shows by default; for a page of a requested semester pass the time to tell current one by its dates.
+func parseSemesterOptions(page *goquery.Selection, now *time.Time) []Semester {
+	result := []Semester{}
+	page.Find("select[name='semester'] option").Each(func(_ int, option *goquery.Selection) {
+		_, selected := option.Attr("selected")
+		s := parseSemesterOption(option.AttrOr("value", ""), option.Text(), selected)
+		if now != nil {
+			s.Current = s.Contains(*now)
+		}
+		result = append(result, s)
+	})
+	return result
+}
+
+// semesterPage fetches marks page of requested semester, current one if id is empty, along with the semester itself.
+// Semester list comes from the page, so usually a single request is needed.
+func (c *Collector) semesterPage(id string, final bool, now time.Time) (Semester, *goquery.Selection, error) {
+	var semester Semester
+	var page *goquery.Selection
+	err := c.withSession(func() error {
+		var err error
+		if c.semesters != nil {
+			if semester, err = resolveSemester(c.semesters, id, now); err != nil {
+				return err
+			}
+			page, err = c.visitMarksPage(semester.ID, final)
+			return err
+		}
+
+		if page, err = c.visitMarksPage(id, final); err != nil {
+			return err
+		}
+		if id != "" {
+			semester, err = resolveSemester(parseSemesterOptions(page, &now), id, now)
+			return err
+		}
+		c.semesters = parseSemesterOptions(page, nil)
+		if semester, err = resolveSemester(c.semesters, "", now); err != nil {
+			return err
+		}
+		// without a selected option it's not known which semester the page shows
+		if !semester.Current {
+			page, err = c.visitMarksPage(semester.ID, final)
+		}
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("setting up collectors: %w", err)
+		return Semester{}, nil, err
 	}
+	return semester, page, nil
+}
 
-	lessonInfoCollector.OnResponse(func(response *colly.Response) {
-		resp, err := parseLessonInfoResponse(string(response.Body))
-		if err != nil {
-			println("FAILED TO PARSE", err.Error())
-			return
+// LessonInfos is the result of scraping a marks table.
+type LessonInfos struct {
+	Lessons []*LessonInfo
+	// Failures lists lessons that are included in Lessons, but their details could not be fetched
+	Failures []LessonFailure
+	// Absences are attendance markers on days without a lesson entry, only meant for SummarizeAttendance
+	Absences []Absence
+	Semester Semester
+	// SchoolYear that day/month pairs in the table were resolved against; all lesson days fall within it.
+	SchoolYear SchoolYear
+}
+
+type lessonInfosOptions struct {
+	semesterID  string
+	skipDetails bool
+}
+
+type LessonInfosOption func(o *lessonInfosOptions)
+
+// WithoutDetails collects only what is in the marks table, skipping a request per lesson for teacher, topic and
+// assignments.
+func WithoutDetails() LessonInfosOption {
+	return func(o *lessonInfosOptions) {
+		o.skipDetails = true
+	}
+}
+
+// WithSemester selects semester to collect lessons for; current semester is used by default.
+func WithSemester(id string) LessonInfosOption {
+	return func(o *lessonInfosOptions) {
+		o.semesterID = id
+	}
+}
+
+// resolveSemester finds requested semester among available ones, defaulting to the current one.
+func resolveSemester(semesters []Semester, id string, now time.Time) (Semester, error) {
+	if id == "" {
+		s, ok := currentSemester(semesters, now)
+		if !ok {
+			return Semester{}, fmt.Errorf("could not determine current semester")
 		}
-		lessonInfo := lessonsByID[response.Request.URL.Query().Get("id")]
-		if lessonInfo != nil {
-			lessonInfo.Teacher = resp.Teacher
-			lessonInfo.Topic = resp.Topic
-			lessonInfo.Assignments = resp.Assignments
+		return s, nil
+	}
+	s, ok := lo.Find(semesters, func(item Semester) bool {
+		return item.ID == id
+	})
+	if !ok {
+		return Semester{}, fmt.Errorf("%w: %s", ErrUnknownSemester, id)
+	}
+	return s, nil
+}
+
+func (c *Collector) GetLessonInfos(opts ...LessonInfosOption) (*LessonInfos, error) {
+	options := lessonInfosOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	now := time.Now()
+	semester, page, err := c.semesterPage(options.semesterID, false, now)
+	if err != nil {
+		return nil, err
+	}
+	schoolYear, ok := semester.SchoolYear()
+	if !ok {
+		schoolYear = SchoolYearOf(now)
+	}
+
+	result, absences := parseMarksTable(page, schoolYear)
+	var failures []LessonFailure
+	if !options.skipDetails {
+		failures = c.fetchLessonDetails(result)
+	}
+
+	slices.SortFunc(result, func(e *LessonInfo, e2 *LessonInfo) int {
+		if e.Day == nil {
+			if e2.Day == nil {
+				return 0
+			}
+			return -1
 		}
+		return e.Day.Compare(*e2.Day)
 	})
+	return &LessonInfos{
+		Lessons:    result,
+		Failures:   failures,
+		Absences:   absences,
+		Semester:   semester,
+		SchoolYear: schoolYear,
+	}, nil
+}
+
+// parseMarksTable reads lessons of a marks page and absences marked on days without a lesson entry of the discipline.
+// Lesson details are not fetched yet.
+func parseMarksTable(page *goquery.Selection, schoolYear SchoolYear) ([]*LessonInfo, []Absence) {
+	lessonsByID := map[string]*LessonInfo{}
+	var unlinked []Absence
 
 	// our table is organized in lots of columns, one column per day. header tells us exact day number
 	// figure out what date each column in the table represents
-	c.c.OnHTML(".marks_table", func(table *colly.HTMLElement) {
+	page.Find(".marks_table").Each(func(_ int, table *goquery.Selection) {
 		tableColumnToDate := map[int]*time.Time{}
-		table.ForEach(".marks_tr_daysrow", func(i int, marksRow *colly.HTMLElement) {
-			marksRow.ForEach("th[id^='m_']", func(col int, th *colly.HTMLElement) {
-				// sample value: m_11_1005_: first int is month, second one is day code.
-				id := th.Attr("id")
-				monthText := strings.Split(id, "_")[1]
-				month, err := strconv.Atoi(monthText)
-				if err != nil {
-					fmt.Printf("FAILED TO PARSE month/day: %s\n", monthText)
+		table.Find(".marks_tr_daysrow th[id^='m_']").Each(func(_ int, th *goquery.Selection) {
+			// sample value: m_11_1005_: first int is month, second one is day code.
+			id := th.AttrOr("id", "")
+			monthText := strings.Split(id, "_")[1]
+			month, err := strconv.Atoi(monthText)
+			if err != nil || month < 1 || month > 12 {
+				log.Printf("skipping marks table column %s: invalid month %q", id, monthText)
+				return
+			}
+			th.Find("table.marks_table_days tr:nth-child(2)").Each(func(_ int, td *goquery.Selection) {
+				day, err := strconv.Atoi(strings.TrimSpace(td.Text()))
+				if err != nil || day < 1 || day > 31 {
+					log.Printf("skipping marks table column %s: invalid day %q", id, td.Text())
 					return
 				}
-				th.ForEach("table.marks_table_days tr:nth-child(2)", func(i int, td *colly.HTMLElement) {
-					day, err := strconv.Atoi(td.Text)
-					if err != nil {
-						println("FAILED TO PARSE month/day: %s", td.Text)
-						return
-					}
-					date := time.Date(2024, time.Month(month), day, 8, 0, 0, 0, time.UTC)
-					tableColumnToDate[th.DOM.Index()] = lo.ToPtr(date)
-				})
+				date := schoolYear.Date(time.Month(month), day)
+				tableColumnToDate[th.Index()] = lo.ToPtr(date)
 			})
 		})
 
 		// now go through each row/col
-		table.ForEach(".marks_tr_discrow", func(discRow int, row *colly.HTMLElement) {
-			discipline := ""
-			row.ForEach(".marks_td_discname", func(i int, element *colly.HTMLElement) {
-				discipline = element.Text
-			})
-			row.ForEach("td[id^='m_']", func(col int, colContent *colly.HTMLElement) {
-				colContent.ForEach(".marks_tr_markrow td[onclick^='tomval_AjaxCmd'].marks_td_markL", func(_ int, element *colly.HTMLElement) {
-					lessonID := parseLessonInfoCommand(element.Attr("onclick"))
-					url := fmt.Sprintf(remoteLocation+"/lessoninfo.php?time=%d&token=%s&id=%s", timestamp, c.loginToken, lessonID)
+		table.Find(".marks_tr_discrow").Each(func(_ int, row *goquery.Selection) {
+			discipline := row.Find(".marks_td_discname").Text()
+			row.Find("td[id^='m_']").Each(func(_ int, colContent *goquery.Selection) {
+				date := tableColumnToDate[colContent.Index()]
+				if date == nil {
+					return
+				}
 
-					date := tableColumnToDate[colContent.DOM.Index()]
-					if date == nil {
+				var dayLessons []*LessonInfo
+				var markers []Attendance
+				colContent.Find(".marks_tr_markrow td").Each(func(_ int, element *goquery.Selection) {
+					lessonID := parseLessonInfoCommand(element.AttrOr("onclick", ""))
+					if lessonID == "" || !element.HasClass("marks_td_markL") {
+						// absences are marked in cells of their own, without lesson details to click on
+						if _, attendance := parseMarkCell(element); attendance != nil {
+							markers = append(markers, *attendance)
+						}
 						return
 					}
 
 					lessonInfo := LessonInfo{
+						ID:          lessonID,
 						Discipline:  discipline,
 						Day:         date,
-						LessonNotes: parseLessonNotes(element.Attr("onmouseover")),
+						LessonNotes: parseLessonNotes(element.AttrOr("onmouseover", "")),
 					}
 					lessonsByID[lessonID] = &lessonInfo
+					dayLessons = append(dayLessons, &lessonInfo)
 
-					element.DOM.Find("span").Remove()
-					mark := strings.TrimSpace(element.DOM.Text())
-					if mark != "" {
-						lessonInfo.Mark = mark
-						println("mark detected:", mark)
+					mark, attendance := parseMarkCell(element)
+					lessonInfo.Attendance = attendance
+					lessonInfo.Mark = mark
+					categoryName := ""
+					if lessonInfo.LessonNotes != nil {
+						categoryName = lessonInfo.LessonNotes.Category
 					}
-
-					_ = lessonInfoCollector.Visit(url)
+					lessonInfo.Marks = parseMarks(mark, categoryName)
 				})
+				unlinked = append(unlinked, assignAttendance(dayLessons, markers, discipline, date)...)
 			})
 		})
-
 	})
 
-	if err := c.c.Visit(fmt.Sprintf(remoteLocation+"/marks.php?time=%d&token=%s&semester=87&alldays=0&final=0", timestamp, c.loginToken)); err != nil {
-		return nil, err
-	}
-
-	lessonInfoCollector.Wait()
-
-	result := lo.Values(lessonsByID)
-	slices.SortFunc(result, func(e *LessonInfo, e2 *LessonInfo) int {
-		if e.Day == nil {
-			if e2.Day == nil {
-				return 0
-			}
-			return -1
-		}
-		return e.Day.Compare(*e2.Day)
-	})
-	return result, nil
+	return lo.Values(lessonsByID), unlinked
 }
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/fakediary"
+)
+
+func TestRestoreCollector(t *testing.T) {
+	r := require.New(t)
+	s := UpstreamSession{
+		Token:       "abc123",
+		StudentName: "Jonas Jonaitis",
+		Cookies:     map[string]string{"PHPSESSID": "sess1"},
+	}
+
+	c, err := RestoreCollector(s, "user", "secret")
+	r.NoError(err)
+	r.Equal("Jonas Jonaitis", c.StudentName)
+	r.Equal(s, c.Session())
+}
+
+func newFakeDiary(t *testing.T) *fakediary.Server {
+	t.Helper()
+	diary := fakediary.NewServer()
+	t.Cleanup(diary.Close)
+	return diary
+}
+
+func TestCollector_Login(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.Error(c.Login(fakediary.User, "wrong"))
+
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+	r.Equal(fakediary.StudentName, c.StudentName)
+	r.Equal(fakediary.ClassName, c.ClassName)
+	r.NotEmpty(c.Session().Token)
+	r.NotEmpty(c.Session().Cookies)
+}
+
+func TestCollector_ListSemesters(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+
+	semesters, err := c.ListSemesters()
+	r.NoError(err)
+	r.Equal([]string{"86", "87"}, lo.Map(semesters, func(item Semester, _ int) string {
+		return item.ID
+	}))
+	r.True(semesters[1].Current)
+	r.Equal("2024-2025 m. m. I pusmetis", semesters[1].Label)
+}
+
+func TestCollector_Disciplines(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+
+	disciplines, err := c.Disciplines()
+	r.NoError(err)
+	r.Equal([]string{"Matematika", "Lietuvių kalba ir literatūra"}, disciplines)
+
+	previous, err := c.Disciplines(WithSemester("86"))
+	r.NoError(err)
+	r.Empty(previous)
+
+	_, err = c.Disciplines(WithSemester("1"))
+	r.ErrorIs(err, ErrUnknownSemester)
+	r.Equal(2, diary.Requests("/marks.php"), "semester list is taken from the first page")
+}
+
+func TestCollector_GetLessonInfos(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+
+	infos, err := c.GetLessonInfos()
+	r.NoError(err)
+	r.Equal(fakediary.CurrentSemester, infos.Semester.ID)
+	r.Equal(2024, infos.SchoolYear.StartYear)
+	r.Equal(1, diary.Requests("/marks.php"), "semesters come along with the marks table")
+	semesters, err := c.ListSemesters()
+	r.NoError(err)
+	r.Len(semesters, 2)
+	r.Equal(1, diary.Requests("/marks.php"))
+
+	lessonsByID := lo.KeyBy(infos.Lessons, func(item *LessonInfo) string {
+		return item.ID
+	})
+	r.Len(infos.Lessons, 4)
+	r.Len(lessonsByID, 4)
+
+	math := lessonsByID["1001"]
+	r.Equal("Matematika", math.Discipline)
+	r.Equal(time.Date(2024, time.December, 18, 8, 0, 0, 0, time.UTC), *math.Day)
+	r.Equal("9", math.Mark)
+	r.Equal([]Mark{{Raw: "9", Kind: Numeric, Value: 9, Weight: 1, Category: "activity", CategoryName: "Aktyvumas ugdymo(si) procese"}}, math.Marks)
+	r.Equal("Petras Petraitis", math.Teacher)
+	r.Equal("Trupmenų sudėtis", math.Topic)
+	r.Equal([]string{"Vadovėlis p. 45, 3 ir 4 uždaviniai"}, math.Assignments)
+	r.Equal(&LessonNotes{Category: "Aktyvumas ugdymo(si) procese", Note: "Jonai, puikiai dirbo pamokoje"}, math.LessonNotes)
+
+	// marks table spans new year
+	r.Equal(time.Date(2025, time.January, 8, 8, 0, 0, 0, time.UTC), *lessonsByID["1002"].Day)
+	r.Nil(math.Attendance)
+	r.Equal(&Attendance{Code: "n", Kind: Absent}, lessonsByID["1002"].Attendance)
+	r.Empty(lessonsByID["1002"].Mark, "absence marker is not a mark")
+	r.Empty(lessonsByID["1002"].Marks)
+	r.Equal(&Attendance{Code: "p", Kind: Late}, lessonsByID["2001"].Attendance)
+	r.Nil(lessonsByID["2002"].Attendance)
+
+	// absence marked on a day without a lesson entry is not a lesson
+	r.Equal([]Absence{{
+		Discipline: "Lietuvių kalba ir literatūra",
+		Day:        lo.ToPtr(time.Date(2025, time.January, 8, 8, 0, 0, 0, time.UTC)),
+		Attendance: Attendance{Code: "nl", Kind: Absent, Justified: true},
+	}}, infos.Absences)
+	r.Equal("Pasakos šaknys", lessonsByID["2001"].Topic)
+	r.Equal([]string{"Iki sausio 10 d. perskaityti pasaką"}, lessonsByID["2001"].Assignments)
+	r.Nil(lessonsByID["2001"].Homework, "homework is resolved against the timetable by the caller")
+
+	// details of this lesson are missing in the diary
+	r.Len(infos.Failures, 1)
+	r.Equal("2002", infos.Failures[0].LessonID)
+	r.Equal("Lietuvių kalba ir literatūra", lessonsByID["2002"].Discipline)
+
+	r.True(slices.IsSortedFunc(infos.Lessons, func(a, b *LessonInfo) int {
+		return a.Day.Compare(*b.Day)
+	}))
+}
+
+func TestCollector_GetLessonInfos_withoutDetails(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+
+	infos, err := c.GetLessonInfos(WithoutDetails())
+	r.NoError(err)
+	r.Len(infos.Lessons, 4)
+	r.Empty(infos.Failures)
+	r.Equal(0, diary.Requests("/lessoninfo.php"))
+}
+
+func TestCollector_GetLessonInfos_semester(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+
+	infos, err := c.GetLessonInfos(WithSemester("86"))
+	r.NoError(err)
+	r.Equal(2023, infos.SchoolYear.StartYear)
+	r.Empty(infos.Lessons)
+	r.False(infos.Semester.Current, "requested semester is not the current one just because it's selected")
+	r.Equal(1, diary.Requests("/marks.php"))
+
+	_, err = c.GetLessonInfos(WithSemester("1"))
+	r.ErrorIs(err, ErrUnknownSemester)
+}
+
+func TestCollector_sessionExpiry(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+	first := c.Session()
+
+	restored, err := RestoreCollector(first, fakediary.User, fakediary.Password, WithBaseURL(diary.URL))
+	r.NoError(err)
+	_, err = restored.ListSemesters()
+	r.NoError(err)
+	r.Equal(1, diary.Logins(), "valid session should be reused")
+	r.Equal(first, restored.Session())
+
+	diary.ExpireSessions()
+	infos, err := restored.GetLessonInfos()
+	r.NoError(err)
+	r.Len(infos.Lessons, 4)
+	r.Equal(2, diary.Logins(), "expired session should be renewed once")
+	r.NotEqual(first, restored.Session())
+
+	// credentials kept in an older session no longer work once the password is changed
+	stale, err := RestoreCollector(first, fakediary.User, "changed", WithBaseURL(diary.URL))
+	r.NoError(err)
+	_, err = stale.ListSemesters()
+	r.ErrorIs(err, ErrLoginFailed)
+}
+
+func TestCollector_GetFinalGrades(t *testing.T) {
+	r := require.New(t)
+	diary := newFakeDiary(t)
+
+	c := NewCollector(WithBaseURL(diary.URL))
+	r.NoError(c.Login(fakediary.User, fakediary.Password))
+
+	grades, err := c.GetFinalGrades()
+	r.NoError(err)
+	r.Equal(fakediary.CurrentSemester, grades.Semester.ID)
+	r.Equal([]string{"Matematika", "Lietuvių kalba ir literatūra", "Kūno kultūra"}, lo.Map(grades.Disciplines, func(item DisciplineGrades, _ int) string {
+		return item.Discipline
+	}))
+	r.Equal([]FinalGrade{
+		{Label: "I pusmetis", Kind: SemesterGrade, Semester: 1, Raw: "8", Marks: []Mark{{Raw: "8", Kind: Numeric, Value: 8, Weight: 1}}},
+	}, grades.Disciplines[1].Grades)
+	r.Equal(Pass, grades.Disciplines[2].Grades[0].Marks[0].Kind)
+
+	previous, err := c.GetFinalGrades(WithSemester("86"))
+	r.NoError(err)
+	r.Equal("86", previous.Semester.ID)
+	r.Equal([]FinalGradeKind{SemesterGrade, SemesterGrade, AnnualGrade, ExamGrade}, lo.Map(previous.Disciplines[0].Grades, func(item FinalGrade, _ int) FinalGradeKind {
+		return item.Kind
+	}))
+	r.Equal("10", previous.Disciplines[0].Grades[3].Raw)
+	r.Len(previous.Disciplines[1].Grades, 3)
+
+	_, err = c.GetFinalGrades(WithSemester("1"))
+	r.ErrorIs(err, ErrUnknownSemester)
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FinalGradeKind tells what period a final grade sums up.
+type FinalGradeKind string
+
+const (
+	SemesterGrade FinalGradeKind = "semester"
+	AnnualGrade   FinalGradeKind = "annual"
+	ExamGrade     FinalGradeKind = "exam"
+	// OtherGrade is any other column of final grades view, e.g. a corrected annual grade
+	OtherGrade FinalGradeKind = "other"
+)
+
+// FinalGrade is a grade of final grades view: semester, annual or exam one.
+type FinalGrade struct {
+	// Label is the column header as shown in the diary, e.g. "I pusmetis"
+	Label string         `json:"label"`
+	Kind  FinalGradeKind `json:"kind"`
+	// Semester is 1 or 2 for semester grades
+	Semester int `json:"semester,omitempty"`
+	// Raw is the grade as shown in the diary, Marks are parsed from it
+	Raw   string `json:"raw"`
+	Marks []Mark `json:"marks,omitempty"`
+}
+
+type DisciplineGrades struct {
+	Discipline string       `json:"discipline"`
+	Grades     []FinalGrade `json:"grades"`
+}
+
+// FinalGrades are grades of final grades view, by discipline in diary order.
+type FinalGrades struct {
+	Semester    Semester           `json:"semester"`
+	Disciplines []DisciplineGrades `json:"disciplines"`
+}
+
+// GetFinalGrades scrapes final grades view of the school year of the semester selected by WithSemester, current one by
+// default. Other options do not apply.
+func (c *Collector) GetFinalGrades(opts ...LessonInfosOption) (*FinalGrades, error) {
+	options := lessonInfosOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	semester, page, err := c.semesterPage(options.semesterID, true, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var disciplines []DisciplineGrades
+	page.Find(".marks_table").Each(func(_ int, table *goquery.Selection) {
+		disciplines = append(disciplines, parseFinalGradesTable(table)...)
+	})
+	if disciplines == nil {
+		disciplines = []DisciplineGrades{}
+	}
+	return &FinalGrades{Semester: semester, Disciplines: disciplines}, nil
+}
+
+// parseFinalGradesTable reads final grades view table: header row names grade columns, each discipline row has a grade
+// per column. Empty cells are grades not given yet and are left out.
+func parseFinalGradesTable(table *goquery.Selection) []DisciplineGrades {
+	labels := map[int]string{}
+	table.Find(".marks_tr_daysrow th").Each(func(_ int, th *goquery.Selection) {
+		labels[th.Index()] = strings.TrimSpace(th.Text())
+	})
+
+	var result []DisciplineGrades
+	table.Find(".marks_tr_discrow").Each(func(_ int, row *goquery.Selection) {
+		grades := DisciplineGrades{
+			Discipline: strings.TrimSpace(row.Find(".marks_td_discname").Text()),
+			Grades:     []FinalGrade{},
+		}
+		row.Children().Not(".marks_td_discname").Each(func(_ int, td *goquery.Selection) {
+			raw := strings.Join(strings.Fields(td.Text()), " ")
+			label, ok := labels[td.Index()]
+			if raw == "" || !ok {
+				return
+			}
+			kind, semester := finalGradeKind(label)
+			grades.Grades = append(grades.Grades, FinalGrade{
+				Label:    label,
+				Kind:     kind,
+				Semester: semester,
+				Raw:      raw,
+				Marks:    parseMarks(raw, ""),
+			})
+		})
+		result = append(result, grades)
+	})
+	return result
+}
+
+// finalGradeKind classifies final grades view column by its header, e.g. "II pusmetis" is the second semester.
+func finalGradeKind(label string) (FinalGradeKind, int) {
+	lower := strings.ToLower(label)
+	switch {
+	case strings.Contains(lower, "egzamin"):
+		return ExamGrade, 0
+	case strings.Contains(lower, "metin"):
+		return AnnualGrade, 0
+	}
+	if m := semesterHalfRegexp.FindStringSubmatch(label); m != nil {
+		return SemesterGrade, len(m[1])
+	}
+	return OtherGrade, 0
+}
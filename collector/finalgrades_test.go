@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinalGradeKind(t *testing.T) {
+	tests := map[string]struct {
+		expectedKind     FinalGradeKind
+		expectedSemester int
+	}{
+		"I pusmetis":              {expectedKind: SemesterGrade, expectedSemester: 1},
+		"II pusm.":                {expectedKind: SemesterGrade, expectedSemester: 2},
+		"ii pusmečio":             {expectedKind: SemesterGrade, expectedSemester: 2},
+		"Metinis":                 {expectedKind: AnnualGrade},
+		"Metinis (patikslintas)":  {expectedKind: AnnualGrade},
+		"Egzaminas":               {expectedKind: ExamGrade},
+		"Brandos egzamino įvert.": {expectedKind: ExamGrade},
+		"Papildomi darbai":        {expectedKind: OtherGrade},
+	}
+
+	for label, tt := range tests {
+		t.Run(label, func(t *testing.T) {
+			kind, semester := finalGradeKind(label)
+			require.Equal(t, tt.expectedKind, kind)
+			require.Equal(t, tt.expectedSemester, semester)
+		})
+	}
+}
+
+func TestParseFinalGradesTable(t *testing.T) {
+	r := require.New(t)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table class="marks_table">
+		<tr class="marks_tr_daysrow"><th>Dalykas</th><th>I pusmetis</th><th>Metinis</th></tr>
+		<tr class="marks_tr_discrow"><td class="marks_td_discname"> Matematika </td><td>9</td><td></td></tr>
+		<tr class="marks_tr_discrow"><td class="marks_td_discname">Kūno kultūra</td><td>įsk</td><td>neįsk</td><td>extra</td></tr>
+	</table>`))
+	r.NoError(err)
+
+	r.Equal([]DisciplineGrades{
+		{Discipline: "Matematika", Grades: []FinalGrade{
+			{Label: "I pusmetis", Kind: SemesterGrade, Semester: 1, Raw: "9", Marks: []Mark{{Raw: "9", Kind: Numeric, Value: 9, Weight: 1}}},
+		}},
+		{Discipline: "Kūno kultūra", Grades: []FinalGrade{
+			{Label: "I pusmetis", Kind: SemesterGrade, Semester: 1, Raw: "įsk", Marks: []Mark{{Raw: "įsk", Kind: Pass}}},
+			{Label: "Metinis", Kind: AnnualGrade, Raw: "neįsk", Marks: []Mark{{Raw: "neįsk", Kind: Fail}}},
+		}},
+	}, parseFinalGradesTable(doc.Find(".marks_table")))
+}
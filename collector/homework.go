@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// DueConfidence tells how sure due date of an assignment is.
+type DueConfidence string
+
+const (
+	// DueHigh is an explicit date ("iki rugsėjo 12 d.") or the next lesson taken from the timetable
+	DueHigh DueConfidence = "high"
+	// DueMedium is a weekday, "rytoj" or a day of month without the month
+	DueMedium DueConfidence = "medium"
+	// DueLow is the next lesson without timetable, assumed a week after, or "kitai savaitei"
+	DueLow DueConfidence = "low"
+	// DueUnknown is an assignment without any recognized date expression
+	DueUnknown DueConfidence = "none"
+)
+
+// Homework is an assignment of a lesson (one of LessonInfo.Assignments) with its due date, when assignment text tells
+// it.
+type Homework struct {
+	Text       string        `json:"text"`
+	Due        *time.Time    `json:"due,omitempty"`
+	Confidence DueConfidence `json:"confidence"`
+}
+
+// lithuanianMonths are genitive month names, as used in dates ("rugsėjo 12 d.").
+var lithuanianMonths = map[string]time.Month{
+	"sausio":    time.January,
+	"vasario":   time.February,
+	"kovo":      time.March,
+	"balandžio": time.April,
+	"gegužės":   time.May,
+	"birželio":  time.June,
+	"liepos":    time.July,
+	"rugpjūčio": time.August,
+	"rugsėjo":   time.September,
+	"spalio":    time.October,
+	"lapkričio": time.November,
+	"gruodžio":  time.December,
+}
+
+// lithuanianWeekdays are weekday stems, followed by any case ending ("penktadienio", "penktadieniui").
+var lithuanianWeekdays = map[string]time.Weekday{
+	"pirmadien":    time.Monday,
+	"antradien":    time.Tuesday,
+	"trečiadien":   time.Wednesday,
+	"ketvirtadien": time.Thursday,
+	"penktadien":   time.Friday,
+	"šeštadien":    time.Saturday,
+	"sekmadien":    time.Sunday,
+}
+
+var (
+	monthDateRegexp = regexp.MustCompile(`(?:^|[^\p{L}])(sausio|vasario|kovo|balandžio|gegužės|birželio|liepos|rugpjūčio|rugsėjo|spalio|lapkričio|gruodžio)\s+(\d{1,2})\b`)
+	// numeric dates are year-month-day, month-day with a dash, or Lithuanian short day.month
+	fullDateRegexp   = regexp.MustCompile(`(?:^|[^\p{L}])iki\s+(\d{4})[-.](\d{1,2})[-.](\d{1,2})\b`)
+	monthDayRegexp   = regexp.MustCompile(`(?:^|[^\p{L}])iki\s+(\d{1,2})-(\d{1,2})\b`)
+	dayMonthRegexp   = regexp.MustCompile(`(?:^|[^\p{L}])iki\s+(\d{1,2})\.(\d{1,2})\b`)
+	dayOfMonthRegexp = regexp.MustCompile(`(?:^|[^\p{L}])iki\s+(\d{1,2})\s*d\.?(?:$|[^\p{L}])`)
+	nextLessonRegexp = regexp.MustCompile(`(?:^|[^\p{L}])(kit(ai|ą|os)|sekanči(ai|ą|os))\s+pamok`)
+	weekdayRegexp    = regexp.MustCompile(`(?:^|[^\p{L}])(pirmadien|antradien|trečiadien|ketvirtadien|penktadien|šeštadien|sekmadien)\p{L}*`)
+	tomorrowRegexp   = regexp.MustCompile(`(?:^|[^\p{L}])rytoj(?:$|[^\p{L}])`)
+	nextWeekRegexp   = regexp.MustCompile(`(?:^|[^\p{L}])(kitai|kitą|sekančiai|sekančią)\s+savait`)
+)
+
+// ResolveHomework sets Homework from lesson assignments, with due dates relative to lesson day. nextLesson is the next
+// lesson of the discipline after this one according to the timetable, if known. Collector leaves it to the caller, as
+// only the caller knows the timetable.
+func (l *LessonInfo) ResolveHomework(nextLesson *time.Time) {
+	l.Homework = nil
+	if l.Day == nil {
+		return
+	}
+	for _, assignment := range l.Assignments {
+		due, confidence := parseDueDate(assignment, *l.Day, nextLesson)
+		l.Homework = append(l.Homework, Homework{Text: assignment, Due: due, Confidence: confidence})
+	}
+}
+
+// parseDueDate recognizes Lithuanian date expressions in assignment text ("iki rugsėjo 12 d.", "kitai pamokai",
+// "iki penktadienio") and resolves them relative to lesson day. Dates without a year fall within a year after the
+// lesson.
+func parseDueDate(text string, lessonDay time.Time, nextLesson *time.Time) (*time.Time, DueConfidence) {
+	text = strings.ToLower(text)
+	day := time.Date(lessonDay.Year(), lessonDay.Month(), lessonDay.Day(), 0, 0, 0, 0, lessonDay.Location())
+
+	if m := monthDateRegexp.FindStringSubmatch(text); m != nil {
+		dayOfMonth, _ := strconv.Atoi(m[2])
+		if due, ok := dateOnOrAfter(day, 0, lithuanianMonths[m[1]], dayOfMonth); ok {
+			return &due, DueHigh
+		}
+	}
+	if m := fullDateRegexp.FindStringSubmatch(text); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		dayOfMonth, _ := strconv.Atoi(m[3])
+		if due, ok := dateOnOrAfter(day, year, time.Month(month), dayOfMonth); ok {
+			return &due, DueHigh
+		}
+	}
+	if m := monthDayRegexp.FindStringSubmatch(text); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		dayOfMonth, _ := strconv.Atoi(m[2])
+		if due, ok := dateOnOrAfter(day, 0, time.Month(month), dayOfMonth); ok {
+			return &due, DueHigh
+		}
+	}
+	// "iki 01.15" is not a date at all: month comes second
+	if m := dayMonthRegexp.FindStringSubmatch(text); m != nil {
+		dayOfMonth, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		if due, ok := dateOnOrAfter(day, 0, time.Month(month), dayOfMonth); ok {
+			return &due, DueHigh
+		}
+	}
+	if m := dayOfMonthRegexp.FindStringSubmatch(text); m != nil {
+		dayOfMonth, _ := strconv.Atoi(m[1])
+		// a day already past is in the next month, which may be in the next year
+		month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		if dayOfMonth < day.Day() {
+			month = month.AddDate(0, 1, 0)
+		}
+		if due, ok := dateOnOrAfter(day, month.Year(), month.Month(), dayOfMonth); ok {
+			return &due, DueMedium
+		}
+	}
+	if nextLessonRegexp.MatchString(text) {
+		if nextLesson != nil {
+			return nextLesson, DueHigh
+		}
+		return lo.ToPtr(day.AddDate(0, 0, 7)), DueLow
+	}
+	if m := weekdayRegexp.FindStringSubmatch(text); m != nil {
+		offset := (int(lithuanianWeekdays[m[1]]) - int(day.Weekday()) + 7) % 7
+		if offset == 0 {
+			offset = 7
+		}
+		return lo.ToPtr(day.AddDate(0, 0, offset)), DueMedium
+	}
+	if tomorrowRegexp.MatchString(text) {
+		return lo.ToPtr(day.AddDate(0, 0, 1)), DueMedium
+	}
+	if nextWeekRegexp.MatchString(text) {
+		return lo.ToPtr(weekStart(day).AddDate(0, 0, 7)), DueLow
+	}
+	return nil, DueUnknown
+}
+
+// dateOnOrAfter builds a date of given year; without one (0) it is the first such date not before day. Invalid dates,
+// e.g. February 30, are rejected.
+func dateOnOrAfter(day time.Time, year int, month time.Month, dayOfMonth int) (time.Time, bool) {
+	if month < time.January || month > time.December {
+		return time.Time{}, false
+	}
+	explicitYear := year != 0
+	if !explicitYear {
+		year = day.Year()
+	}
+	result := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, day.Location())
+	if result.Day() != dayOfMonth {
+		return time.Time{}, false
+	}
+	if result.Before(day) && !explicitYear {
+		result = result.AddDate(1, 0, 0)
+	}
+	return result, true
+}
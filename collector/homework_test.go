@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDueDate(t *testing.T) {
+	// a Wednesday
+	lessonDay := time.Date(2024, time.December, 18, 10, 0, 0, 0, time.UTC)
+	nextLesson := time.Date(2024, time.December, 20, 9, 0, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day int) *time.Time {
+		return lo.ToPtr(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	}
+
+	tests := map[string]struct {
+		text               string
+		nextLesson         *time.Time
+		expectedDue        *time.Time
+		expectedConfidence DueConfidence
+	}{
+		"month and day": {
+			text:               "Iki gruodžio 23 d. labai prašau nusipirkti pratybas",
+			expectedDue:        date(2024, time.December, 23),
+			expectedConfidence: DueHigh,
+		},
+		"month and day of next year": {
+			text:               "Iki sausio 10 d. perskaityti pasaką",
+			expectedDue:        date(2025, time.January, 10),
+			expectedConfidence: DueHigh,
+		},
+		"numeric date": {
+			text:               "Projektą pateikti iki 2025-01-15",
+			expectedDue:        date(2025, time.January, 15),
+			expectedConfidence: DueHigh,
+		},
+		"numeric date with dots": {
+			text:               "Projektą pateikti iki 2025.01.15",
+			expectedDue:        date(2025, time.January, 15),
+			expectedConfidence: DueHigh,
+		},
+		"month and day with dash": {
+			text:               "Pristatyti iki 01-15",
+			expectedDue:        date(2025, time.January, 15),
+			expectedConfidence: DueHigh,
+		},
+		"day and month": {
+			text:               "Pristatyti iki 15.01",
+			expectedDue:        date(2025, time.January, 15),
+			expectedConfidence: DueHigh,
+		},
+		"day and month later this year": {
+			text:               "Pristatyti iki 20.12",
+			expectedDue:        date(2024, time.December, 20),
+			expectedConfidence: DueHigh,
+		},
+		"month first with dots is not a date": {
+			text:               "Pristatyti iki 01.15",
+			expectedConfidence: DueUnknown,
+		},
+		"invalid date": {
+			text:               "Iki vasario 30 d.",
+			expectedConfidence: DueUnknown,
+		},
+		"day of month": {
+			text:               "Iki 27 d. išmokti eilėraštį",
+			expectedDue:        date(2024, time.December, 27),
+			expectedConfidence: DueMedium,
+		},
+		"day of next month in next year": {
+			text:               "Iki 3 d. išmokti eilėraštį",
+			expectedDue:        date(2025, time.January, 3),
+			expectedConfidence: DueMedium,
+		},
+		"next lesson from timetable": {
+			text:               "Kitai pamokai atsinešti žirkles",
+			nextLesson:         &nextLesson,
+			expectedDue:        &nextLesson,
+			expectedConfidence: DueHigh,
+		},
+		"next lesson without timetable": {
+			text:               "Pasikartoti iki kitos pamokos",
+			expectedDue:        date(2024, time.December, 25),
+			expectedConfidence: DueLow,
+		},
+		"weekday": {
+			text:               "Iki penktadienio atlikti 5 pratimą",
+			expectedDue:        date(2024, time.December, 20),
+			expectedConfidence: DueMedium,
+		},
+		"same weekday is next week": {
+			text:               "Trečiadienį rašysime diktantą",
+			expectedDue:        date(2024, time.December, 25),
+			expectedConfidence: DueMedium,
+		},
+		"tomorrow": {
+			text:               "Rytoj atsinešti sportinę aprangą",
+			expectedDue:        date(2024, time.December, 19),
+			expectedConfidence: DueMedium,
+		},
+		"next week": {
+			text:               "Kitai savaitei paruošti pristatymą",
+			expectedDue:        date(2024, time.December, 23),
+			expectedConfidence: DueLow,
+		},
+		"page numbers are not dates": {
+			text:               "Vadovėlis p. 45, 3.5 ir 4 uždaviniai",
+			expectedConfidence: DueUnknown,
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			due, confidence := parseDueDate(tt.text, lessonDay, tt.nextLesson)
+			r.Equal(tt.expectedDue, due)
+			r.Equal(tt.expectedConfidence, confidence)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// fetchPolicy controls how lesson details are downloaded.
+type fetchPolicy struct {
+	// workers is the number of lesson details fetched in parallel
+	workers int
+	// attempts is the total number of tries for a transient failure
+	attempts int
+	// backoff is the delay before first retry, doubled for each subsequent one
+	backoff time.Duration
+}
+
+var defaultFetchPolicy = fetchPolicy{
+	workers:  10,
+	attempts: 3,
+	backoff:  500 * time.Millisecond,
+}
+
+// LessonFailure describes a lesson whose details could not be fetched. Lesson itself is still listed, just without
+// teacher, topic and assignments.
+type LessonFailure struct {
+	LessonID string `json:"lessonId"`
+	Err      error  `json:"-"`
+}
+
+// MarshalJSON serializes Err as its message, as errors don't serialize on their own.
+func (f LessonFailure) MarshalJSON() ([]byte, error) {
+	type failure LessonFailure
+	return json.Marshal(struct {
+		failure
+		Error string `json:"error,omitempty"`
+	}{failure: failure(f), Error: errorMessage(f.Err)})
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// errTransient marks failures that are worth retrying: network errors, timeouts and server side errors.
+var errTransient = errors.New("transient failure")
+
+const lessonInfoResponseKey = "lessonInfoResponse"
+
+// fetchLessonDetails downloads details for all given lessons with a bounded worker pool, retrying transient failures.
+// Each lesson is updated by a single worker only; lessons that could not be completed are reported as failures.
+func (c *Collector) fetchLessonDetails(lessons []*LessonInfo) []LessonFailure {
+	detailsCollector := c.c.Clone()
+	// status codes are checked here, to tell transient errors from permanent ones
+	detailsCollector.ParseHTTPErrorResponse = true
+	detailsCollector.OnResponse(func(response *colly.Response) {
+		response.Ctx.Put(lessonInfoResponseKey, response)
+	})
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	jobs := make(chan *LessonInfo)
+	var (
+		mu       sync.Mutex
+		failures []LessonFailure
+		wg       sync.WaitGroup
+	)
+	for range c.fetch.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lesson := range jobs {
+				details, err := c.fetchLessonDetailsWithRetry(detailsCollector, timestamp, lesson.ID)
+				if err != nil {
+					mu.Lock()
+					failures = append(failures, LessonFailure{LessonID: lesson.ID, Err: err})
+					mu.Unlock()
+					continue
+				}
+				lesson.Teacher = details.Teacher
+				lesson.Topic = details.Topic
+				lesson.Assignments = details.Assignments
+			}
+		}()
+	}
+
+	for _, lesson := range lessons {
+		jobs <- lesson
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failures
+}
+
+func (c *Collector) fetchLessonDetailsWithRetry(cc *colly.Collector, timestamp int64, lessonID string) (*LessonInfo, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	backoff := c.fetch.backoff
+	for attempt := 1; ; attempt++ {
+		details, err := fetchLessonDetail(cc, fmt.Sprintf(c.baseURL+"/lessoninfo.php?time=%d&token=%s&id=%s", timestamp, c.loginToken, lessonID))
+		if err == nil || !errors.Is(err, errTransient) || attempt >= c.fetch.attempts {
+			return details, err
+		}
+		// nobody waits for the details once request is cancelled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func fetchLessonDetail(cc *colly.Collector, url string) (*LessonInfo, error) {
+	ctx := colly.NewContext()
+	if err := cc.Request(http.MethodGet, url, nil, ctx, nil); err != nil {
+		// with HTTP error responses parsed, remaining errors are network errors and timeouts
+		return nil, fmt.Errorf("%w: %w", errTransient, err)
+	}
+
+	response, ok := ctx.GetAny(lessonInfoResponseKey).(*colly.Response)
+	if !ok {
+		return nil, fmt.Errorf("no response received")
+	}
+	if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: status %d", errTransient, response.StatusCode)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	return parseLessonInfoResponse(string(response.Body))
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLessonInfoTransport serves lessoninfo.php responses; lessons listed in failuresByID fail that many times first
+type fakeLessonInfoTransport struct {
+	mu           sync.Mutex
+	failuresByID map[string]int
+	statusByID   map[string]int
+	requests     map[string]int
+}
+
+func (t *fakeLessonInfoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := req.URL.Query().Get("id")
+
+	t.mu.Lock()
+	t.requests[id]++
+	failures := t.failuresByID[id]
+	t.failuresByID[id]--
+	status, hasStatus := t.statusByID[id]
+	t.mu.Unlock()
+
+	if failures > 0 {
+		return nil, errors.New("connection reset by peer")
+	}
+	if !hasStatus {
+		status = http.StatusOK
+	}
+
+	body := fmt.Sprintf(`<b>Mokytoja(s): </b>Mokytojas %s<br /><br /><b>Tema: </b>Tema %s<br /><br /><b>Užduotys: </b><br />`, id, id)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestFetchLessonDetails(t *testing.T) {
+	r := require.New(t)
+	transport := &fakeLessonInfoTransport{
+		failuresByID: map[string]int{"3": 1, "4": 10},
+		statusByID:   map[string]int{"5": http.StatusNotFound, "6": http.StatusServiceUnavailable},
+		requests:     map[string]int{},
+	}
+
+	c := NewCollector()
+	c.WithTransport(transport)
+	c.fetch = fetchPolicy{workers: 4, attempts: 3, backoff: time.Millisecond}
+
+	var lessons []*LessonInfo
+	for i := range 50 {
+		lessons = append(lessons, &LessonInfo{ID: fmt.Sprint(i)})
+	}
+
+	failures := c.fetchLessonDetails(lessons)
+
+	failedIDs := map[string]bool{}
+	for _, f := range failures {
+		failedIDs[f.LessonID] = true
+	}
+	r.Equal(map[string]bool{"4": true, "5": true, "6": true}, failedIDs)
+
+	for _, l := range lessons {
+		if failedIDs[l.ID] {
+			r.Empty(l.Teacher)
+			continue
+		}
+		r.Equal("Mokytojas "+l.ID, l.Teacher, "lesson %s", l.ID)
+		r.Equal("Tema "+l.ID, l.Topic)
+	}
+
+	r.Equal(2, transport.requests["3"], "transient failure should be retried")
+	r.Equal(3, transport.requests["4"], "retries should stop after configured attempts")
+	r.Equal(1, transport.requests["5"], "client errors should not be retried")
+	r.Equal(3, transport.requests["6"], "server errors should be retried")
+}
+
+func TestFetchLessonDetails_cancelledContextStopsRetries(t *testing.T) {
+	r := require.New(t)
+	transport := &fakeLessonInfoTransport{
+		failuresByID: map[string]int{"1": 10},
+		requests:     map[string]int{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewCollector(WithContext(ctx))
+	c.WithTransport(transport)
+	c.fetch = fetchPolicy{workers: 1, attempts: 10, backoff: time.Hour}
+
+	done := make(chan []LessonFailure)
+	go func() {
+		done <- c.fetchLessonDetails([]*LessonInfo{{ID: "1"}})
+	}()
+
+	// let the first attempt fail before cancelling the backoff wait
+	r.Eventually(func() bool {
+		transport.mu.Lock()
+		defer transport.mu.Unlock()
+		return transport.requests["1"] == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case failures := <-done:
+		r.Len(failures, 1)
+		r.Equal("1", failures[0].LessonID)
+	case <-time.After(time.Second):
+		r.Fail("retry backoff should stop once context is cancelled")
+	}
+	r.Equal(1, transport.requests["1"])
+}
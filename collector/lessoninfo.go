@@ -11,6 +11,7 @@ import (
 )
 
 var lessonInfoRegexp = regexp.MustCompile(`tomval_AjaxCmd\('(\w+)', '(\w+)', '(\w+)', this\); return false`)
+var classNameRegexp = regexp.MustCompile(`(?i)\b(\d{1,2})\s*([a-z])\b`)
 var lessonNotesRegexp = regexp.MustCompile(`showhint\('(.*)', this, null, '', true, true\)`)
 
 type LessonNotes struct {
@@ -18,15 +19,35 @@ type LessonNotes struct {
 	Note     string `json:"note,omitempty"`
 }
 
+// ScheduledLesson is a lesson as placed in the school timetable.
+type ScheduledLesson struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Period  string    `json:"period,omitempty"`
+	Room    string    `json:"room,omitempty"`
+	Teacher string    `json:"teacher,omitempty"`
+}
+
 type LessonInfo struct {
-	Discipline  string       `json:"discipline,omitempty"`
-	Day         *time.Time   `json:"day,omitempty"`
-	Teacher     string       `json:"teacher,omitempty"`
-	Topic       string       `json:"topic,omitempty"`
-	Assignments []string     `json:"assignments,omitempty"`
-	NextDates   []time.Time  `json:"nextDates,omitempty"`
+	ID          string      `json:"id,omitempty"`
+	Discipline  string      `json:"discipline,omitempty"`
+	Day         *time.Time  `json:"day,omitempty"`
+	Teacher     string      `json:"teacher,omitempty"`
+	Topic       string      `json:"topic,omitempty"`
+	Assignments []string    `json:"assignments,omitempty"`
+	Homework    []Homework  `json:"homework,omitempty"`
+	NextDates   []time.Time `json:"nextDates,omitempty"`
+	// Scheduled is the timetable slot of the lesson; NextLessons are upcoming ones, matching NextDates
+	Scheduled   *ScheduledLesson  `json:"scheduled,omitempty"`
+	NextLessons []ScheduledLesson `json:"nextLessons,omitempty"`
+	// Mark is the raw marks table text, Marks are parsed from it
 	Mark        string       `json:"mark,omitempty"`
+	Marks       []Mark       `json:"marks,omitempty"`
+	Attendance  *Attendance  `json:"attendance,omitempty"`
 	LessonNotes *LessonNotes `json:"lessonNotes,omitempty"`
+	// Cancelled and SubstituteTeacher come from edupage substitutions of the lesson's day; a changed room is in Scheduled
+	Cancelled         bool   `json:"cancelled,omitempty"`
+	SubstituteTeacher string `json:"substituteTeacher,omitempty"`
 }
 
 // parseLessonInfoCommand takes an onclick handler value and extracts lesson ID from it
@@ -38,6 +59,15 @@ func parseLessonInfoCommand(input string) string {
 	return m[3]
 }
 
+// parseClassName finds class name such as "5d" in diary header text ("5d klasė", "5 D kl.").
+func parseClassName(text string) string {
+	m := classNameRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	return m[1] + strings.ToLower(m[2])
+}
+
 func parseLessonNotes(attr string) *LessonNotes {
 	m := lessonNotesRegexp.FindStringSubmatch(attr)
 	if len(m) != 2 {
@@ -58,3 +58,20 @@ func Test_parseLessonNotes(t *testing.T) {
 	r.Equal("Ačiū už aktyvų dalyvavimą pamokoje :)", result.Note)
 	r.Equal("Aktyvumas ugdymo(si) procese", result.Category)
 }
+
+func Test_parseClassName(t *testing.T) {
+	tests := map[string]string{
+		"5d klasė":  "5d",
+		"5 D kl.":   "5d",
+		"12a":       "12a",
+		"Mokinys":   "",
+		"2024 m.":   "",
+		"Klasė: 7b": "7b",
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			require.Equal(t, expected, parseClassName(input))
+		})
+	}
+}
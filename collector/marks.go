@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MarkKind tells how a mark is given.
+type MarkKind string
+
+const (
+	// Numeric marks are 1 to 10
+	Numeric MarkKind = "numeric"
+	// Pass is "įsk" (įskaityta)
+	Pass MarkKind = "pass"
+	// Fail is "neįsk" (neįskaityta)
+	Fail MarkKind = "fail"
+	// OtherMark is anything else the diary puts in a mark cell; only Raw is known
+	OtherMark MarkKind = "other"
+)
+
+// Mark is a single mark of a lesson, parsed from marks table text.
+type Mark struct {
+	// Raw is the mark as shown in the diary
+	Raw   string   `json:"raw"`
+	Kind  MarkKind `json:"kind"`
+	Value int      `json:"value,omitempty"`
+	// Weight of the mark in an average, resolved from MarkWeights by category; only numeric marks count
+	Weight float64 `json:"weight"`
+	// Category is the kind of work marked: "test", "homework", "project", "classwork" or "activity"; empty when diary
+	// doesn't tell or it's something else
+	Category string `json:"category,omitempty"`
+	// CategoryName is the category as named in the diary, e.g. "Kontrolinis darbas"
+	CategoryName string `json:"categoryName,omitempty"`
+}
+
+// markCategories map beginnings of diary category names to categories.
+var markCategories = []struct {
+	prefix   string
+	category string
+}{
+	{"kontrolin", "test"},
+	{"testas", "test"},
+	{"atsiskaitom", "test"},
+	{"namų darb", "homework"},
+	{"projekt", "project"},
+	{"savarankišk", "classwork"},
+	{"klasės darb", "classwork"},
+	{"praktin", "classwork"},
+	{"aktyvum", "activity"},
+}
+
+// parseMarks splits marks table cell text into marks; a cell may hold several, e.g. "9 10" or "9/10". categoryName is
+// the category of lesson notes, if any.
+func parseMarks(text string, categoryName string) []Mark {
+	category := markCategory(categoryName)
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == '/' || r == ',' || r == ';' || r == '\t' || r == '\n'
+	})
+
+	var result []Mark
+	for _, token := range tokens {
+		m := Mark{Raw: token, Kind: OtherMark, Category: category, CategoryName: categoryName}
+		switch normalized := strings.ToLower(strings.TrimSuffix(token, ".")); {
+		case normalized == "įsk" || normalized == "įskaityta":
+			m.Kind = Pass
+		case normalized == "neįsk" || normalized == "neįskaityta":
+			m.Kind = Fail
+		default:
+			if value, err := strconv.Atoi(normalized); err == nil && value >= 1 && value <= 10 {
+				m.Kind, m.Value, m.Weight = Numeric, value, 1
+			}
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func markCategory(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, c := range markCategories {
+		if strings.HasPrefix(name, c.prefix) {
+			return c.category
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarks(t *testing.T) {
+	tests := map[string]struct {
+		text         string
+		categoryName string
+		expected     []Mark
+	}{
+		"empty": {text: ""},
+		"numeric": {
+			text:     "9",
+			expected: []Mark{{Raw: "9", Kind: Numeric, Value: 9, Weight: 1}},
+		},
+		"several in a cell": {
+			text: "9/10 8",
+			expected: []Mark{
+				{Raw: "9", Kind: Numeric, Value: 9, Weight: 1},
+				{Raw: "10", Kind: Numeric, Value: 10, Weight: 1},
+				{Raw: "8", Kind: Numeric, Value: 8, Weight: 1},
+			},
+		},
+		"pass and fail": {
+			text:     "įsk neĮsk.",
+			expected: []Mark{{Raw: "įsk", Kind: Pass}, {Raw: "neĮsk.", Kind: Fail}},
+		},
+		"out of range": {
+			text:     "11 0",
+			expected: []Mark{{Raw: "11", Kind: OtherMark}, {Raw: "0", Kind: OtherMark}},
+		},
+		"test": {
+			text:         "7",
+			categoryName: "Kontrolinis darbas",
+			expected:     []Mark{{Raw: "7", Kind: Numeric, Value: 7, Weight: 1, Category: "test", CategoryName: "Kontrolinis darbas"}},
+		},
+		"homework": {
+			text:         "10",
+			categoryName: "Namų darbai",
+			expected:     []Mark{{Raw: "10", Kind: Numeric, Value: 10, Weight: 1, Category: "homework", CategoryName: "Namų darbai"}},
+		},
+		"unknown category": {
+			text:         "6",
+			categoryName: "Kita",
+			expected:     []Mark{{Raw: "6", Kind: Numeric, Value: 6, Weight: 1, CategoryName: "Kita"}},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			require.Equal(t, tt.expected, parseMarks(tt.text, tt.categoryName))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"time"
+)
+
+// SchoolYear is an academic year running from September to August. It is identified by the calendar year it starts in,
+// so school year 2024 covers 2024-09-01 to 2025-08-31.
+type SchoolYear struct {
+	StartYear int `json:"startYear"`
+}
+
+// SchoolYearOf returns the school year that given date belongs to.
+func SchoolYearOf(t time.Time) SchoolYear {
+	if t.Month() >= time.September {
+		return SchoolYear{StartYear: t.Year()}
+	}
+	return SchoolYear{StartYear: t.Year() - 1}
+}
+
+// Start returns first day of the school year.
+func (y SchoolYear) Start() time.Time {
+	return time.Date(y.StartYear, time.September, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// End returns first day of the next school year (exclusive bound).
+func (y SchoolYear) End() time.Time {
+	return time.Date(y.StartYear+1, time.September, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Contains reports whether given date falls within the school year.
+func (y SchoolYear) Contains(t time.Time) bool {
+	return !t.Before(y.Start()) && t.Before(y.End())
+}
+
+// Date resolves a month/day pair, as shown in marks table headers, to a full date within the school year.
+// September to December belong to the starting year, January to August - to the next one.
+func (y SchoolYear) Date(month time.Month, day int) time.Time {
+	year := y.StartYear
+	if month < time.September {
+		year++
+	}
+	return time.Date(year, month, day, 8, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchoolYearOf(t *testing.T) {
+	tests := map[string]struct {
+		date     time.Time
+		expected int
+	}{
+		"autumn":           {date: time.Date(2024, time.October, 5, 0, 0, 0, 0, time.UTC), expected: 2024},
+		"first day":        {date: time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC), expected: 2024},
+		"after new year":   {date: time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC), expected: 2024},
+		"summer break end": {date: time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC), expected: 2024},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			require.Equal(t, tt.expected, SchoolYearOf(tt.date).StartYear)
+		})
+	}
+}
+
+func TestSchoolYear_Date(t *testing.T) {
+	r := require.New(t)
+	y := SchoolYear{StartYear: 2024}
+
+	december := y.Date(time.December, 20)
+	january := y.Date(time.January, 6)
+	r.Equal(time.Date(2024, time.December, 20, 8, 0, 0, 0, time.UTC), december)
+	r.Equal(time.Date(2025, time.January, 6, 8, 0, 0, 0, time.UTC), january)
+	r.True(january.After(december))
+	r.True(y.Contains(december))
+	r.True(y.Contains(january))
+	r.False(y.Contains(y.End()))
+}
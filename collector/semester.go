@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+var semesterDateRegexp = regexp.MustCompile(`(\d{4})[-.](\d{2})[-.](\d{2})`)
+var semesterYearsRegexp = regexp.MustCompile(`(\d{4})\s*[-–/]\s*(\d{4})`)
+var semesterHalfRegexp = regexp.MustCompile(`(?i)\b(I{1,2})\s+pusm`)
+
+// Semester is one of the periods the diary splits school year into; marks table is always viewed for a single semester.
+type Semester struct {
+	ID      string     `json:"id"`
+	Label   string     `json:"label"`
+	From    *time.Time `json:"from,omitempty"`
+	To      *time.Time `json:"to,omitempty"`
+	Current bool       `json:"current,omitempty"`
+}
+
+// SchoolYear returns school year the semester belongs to, if semester date range is known.
+func (s Semester) SchoolYear() (SchoolYear, bool) {
+	if s.From == nil {
+		return SchoolYear{}, false
+	}
+	return SchoolYearOf(*s.From), true
+}
+
+// Contains reports whether given date falls within semester date range; semesters without known range contain nothing.
+func (s Semester) Contains(t time.Time) bool {
+	if s.From == nil || s.To == nil {
+		return false
+	}
+	return !t.Before(*s.From) && t.Before(s.To.AddDate(0, 0, 1))
+}
+
+// parseSemesterOption builds semester from an option of semester selector in marks page.
+func parseSemesterOption(value string, label string, selected bool) Semester {
+	label = strings.TrimSpace(label)
+	from, to := parseSemesterLabel(label)
+	return Semester{
+		ID:      strings.TrimSpace(value),
+		Label:   label,
+		From:    from,
+		To:      to,
+		Current: selected,
+	}
+}
+
+// parseSemesterLabel extracts semester date range from its label. Explicit dates ("2024-09-01 - 2025-01-31") are used
+// when present; otherwise range is derived from school year and semester number ("2024-2025 m. m. I pusmetis"), using
+// usual Lithuanian split at the beginning of February.
+func parseSemesterLabel(label string) (*time.Time, *time.Time) {
+	if dates := semesterDateRegexp.FindAllStringSubmatch(label, -1); len(dates) == 2 {
+		from, fromOK := parseSemesterDate(dates[0])
+		to, toOK := parseSemesterDate(dates[1])
+		if fromOK && toOK {
+			return &from, &to
+		}
+	}
+
+	years := semesterYearsRegexp.FindStringSubmatch(label)
+	if years == nil {
+		return nil, nil
+	}
+	startYear, _ := strconv.Atoi(years[1])
+	year := SchoolYear{StartYear: startYear}
+
+	switch half := semesterHalfRegexp.FindStringSubmatch(label); {
+	case half == nil:
+		return lo.ToPtr(year.Start()), lo.ToPtr(year.End().AddDate(0, 0, -1))
+	case len(half[1]) == 1:
+		return lo.ToPtr(year.Start()), lo.ToPtr(time.Date(startYear+1, time.January, 31, 0, 0, 0, 0, time.UTC))
+	default:
+		return lo.ToPtr(time.Date(startYear+1, time.February, 1, 0, 0, 0, 0, time.UTC)), lo.ToPtr(year.End().AddDate(0, 0, -1))
+	}
+}
+
+func parseSemesterDate(m []string) (time.Time, bool) {
+	t, err := time.Parse(time.DateOnly, m[1]+"-"+m[2]+"-"+m[3])
+	return t, err == nil
+}
+
+// currentSemester picks the semester diary marks as selected, falling back to the one containing given date.
+func currentSemester(semesters []Semester, now time.Time) (Semester, bool) {
+	if s, ok := lo.Find(semesters, func(item Semester) bool {
+		return item.Current
+	}); ok {
+		return s, true
+	}
+	return lo.Find(semesters, func(item Semester) bool {
+		return item.Contains(now)
+	})
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSemesterOption(t *testing.T) {
+	tests := map[string]struct {
+		label        string
+		expectedFrom *time.Time
+		expectedTo   *time.Time
+	}{
+		"explicit dates": {
+			label:        "I pusmetis (2024-09-02 - 2025-01-31)",
+			expectedFrom: lo.ToPtr(time.Date(2024, time.September, 2, 0, 0, 0, 0, time.UTC)),
+			expectedTo:   lo.ToPtr(time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)),
+		},
+		"first half": {
+			label:        "2024-2025 m. m. I pusmetis",
+			expectedFrom: lo.ToPtr(time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC)),
+			expectedTo:   lo.ToPtr(time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)),
+		},
+		"second half": {
+			label:        "2024-2025 m. m. II pusmetis",
+			expectedFrom: lo.ToPtr(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)),
+			expectedTo:   lo.ToPtr(time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC)),
+		},
+		"whole year": {
+			label:        "2024-2025 m. m.",
+			expectedFrom: lo.ToPtr(time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC)),
+			expectedTo:   lo.ToPtr(time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC)),
+		},
+		"unknown": {
+			label: "Papildomas",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			got := parseSemesterOption(" 87 ", tt.label, true)
+			r.Equal("87", got.ID)
+			r.Equal(tt.label, got.Label)
+			r.True(got.Current)
+			r.Equal(tt.expectedFrom, got.From)
+			r.Equal(tt.expectedTo, got.To)
+		})
+	}
+}
+
+func TestCurrentSemester(t *testing.T) {
+	r := require.New(t)
+	first := parseSemesterOption("87", "2024-2025 m. m. I pusmetis", false)
+	second := parseSemesterOption("88", "2024-2025 m. m. II pusmetis", false)
+
+	got, ok := currentSemester([]Semester{first, second}, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC))
+	r.True(ok)
+	r.Equal("88", got.ID)
+
+	first.Current = true
+	got, ok = currentSemester([]Semester{first, second}, time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC))
+	r.True(ok)
+	r.Equal("87", got.ID)
+
+	year, ok := got.SchoolYear()
+	r.True(ok)
+	r.Equal(2024, year.StartYear)
+
+	_, ok = currentSemester(nil, time.Now())
+	r.False(ok)
+}
@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// MarkWeights weigh numeric marks in averages by category, e.g. {"test": 2}. Categories not listed weigh 1; marks
+// without a category are weighed by the "" entry.
+type MarkWeights map[string]float64
+
+// ParseMarkWeights parses comma separated category=weight pairs, e.g. "test=2,homework=0.5".
+func ParseMarkWeights(value string) (MarkWeights, error) {
+	result := MarkWeights{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		category, weightText, ok := strings.Cut(item, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightText), 64)
+		if !ok || err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid mark weight %q, expected category=weight", item)
+		}
+		result[strings.TrimSpace(category)] = weight
+	}
+	return result, nil
+}
+
+func (w MarkWeights) of(m Mark) float64 {
+	if weight, ok := w[m.Category]; ok {
+		return weight
+	}
+	return 1
+}
+
+// Resolve sets Weight of numeric lesson marks by their category.
+func (w MarkWeights) Resolve(lessons []*LessonInfo) {
+	for _, l := range lessons {
+		for i, m := range l.Marks {
+			if m.Kind == Numeric {
+				l.Marks[i].Weight = w.of(m)
+			}
+		}
+	}
+}
+
+// MonthAverage is the average of marks given during a month.
+type MonthAverage struct {
+	// Month is "2006-01"
+	Month   string  `json:"month"`
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// DisciplineStats sums up marks of a discipline.
+type DisciplineStats struct {
+	Discipline string `json:"discipline"`
+	// Average is the weighted average of numeric marks; nil without any
+	Average *float64 `json:"average"`
+	// Count is the number of numeric marks; Distribution counts them by value
+	Count        int         `json:"count"`
+	Distribution map[int]int `json:"distribution"`
+	Passed       int         `json:"passed"`
+	Failed       int         `json:"failed"`
+	Lowest       int         `json:"lowest,omitempty"`
+	Highest      int         `json:"highest,omitempty"`
+	// Trend are monthly averages, oldest first
+	Trend []MonthAverage `json:"trend"`
+}
+
+// Stats sums up marks of a semester.
+type Stats struct {
+	// Average is the weighted average of all numeric marks; nil without any
+	Average     *float64          `json:"average"`
+	Count       int               `json:"count"`
+	Disciplines []DisciplineStats `json:"disciplines"`
+	Weights     MarkWeights       `json:"weights"`
+}
+
+// weightedSum accumulates a weighted average.
+type weightedSum struct {
+	sum    float64
+	weight float64
+	count  int
+}
+
+func (s *weightedSum) add(value int, weight float64) {
+	s.sum += float64(value) * weight
+	s.weight += weight
+	s.count++
+}
+
+func (s *weightedSum) average() *float64 {
+	if s.weight == 0 {
+		return nil
+	}
+	return lo.ToPtr(math.Round(s.sum/s.weight*100) / 100)
+}
+
+// ComputeStats sums up marks of lessons by discipline, resolving their Weight first. Disciplines are sorted by name;
+// ones without marks are left out.
+func ComputeStats(lessons []*LessonInfo, weights MarkWeights) Stats {
+	if weights == nil {
+		weights = MarkWeights{}
+	}
+	weights.Resolve(lessons)
+	result := Stats{Disciplines: []DisciplineStats{}, Weights: weights}
+
+	var total weightedSum
+	byDiscipline := lo.GroupBy(lo.Filter(lessons, func(item *LessonInfo, _ int) bool {
+		return len(item.Marks) > 0
+	}), func(item *LessonInfo) string {
+		return item.Discipline
+	})
+	disciplines := lo.Keys(byDiscipline)
+	slices.Sort(disciplines)
+	for _, discipline := range disciplines {
+		stats := DisciplineStats{Discipline: discipline, Distribution: map[int]int{}, Trend: []MonthAverage{}}
+		var disciplineSum weightedSum
+		monthly := map[string]*weightedSum{}
+		for _, l := range byDiscipline[discipline] {
+			for _, m := range l.Marks {
+				switch m.Kind {
+				case Pass:
+					stats.Passed++
+					continue
+				case Fail:
+					stats.Failed++
+					continue
+				case Numeric:
+				default:
+					continue
+				}
+
+				disciplineSum.add(m.Value, m.Weight)
+				total.add(m.Value, m.Weight)
+				stats.Distribution[m.Value]++
+				if stats.Lowest == 0 || m.Value < stats.Lowest {
+					stats.Lowest = m.Value
+				}
+				stats.Highest = max(stats.Highest, m.Value)
+
+				if l.Day == nil {
+					continue
+				}
+				month := l.Day.Format("2006-01")
+				if monthly[month] == nil {
+					monthly[month] = &weightedSum{}
+				}
+				monthly[month].add(m.Value, m.Weight)
+			}
+		}
+		stats.Average = disciplineSum.average()
+		stats.Count = disciplineSum.count
+		months := lo.Keys(monthly)
+		slices.Sort(months)
+		for _, month := range months {
+			if average := monthly[month].average(); average != nil {
+				stats.Trend = append(stats.Trend, MonthAverage{Month: month, Average: *average, Count: monthly[month].count})
+			}
+		}
+		result.Disciplines = append(result.Disciplines, stats)
+	}
+	result.Average = total.average()
+	result.Count = total.count
+	return result
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarkWeights(t *testing.T) {
+	tests := map[string]struct {
+		value         string
+		expected      MarkWeights
+		expectedError bool
+	}{
+		"empty":           {value: "", expected: MarkWeights{}},
+		"weights":         {value: "test=2, homework=0.5", expected: MarkWeights{"test": 2, "homework": 0.5}},
+		"uncategorized":   {value: "=0", expected: MarkWeights{"": 0}},
+		"missing weight":  {value: "test", expectedError: true},
+		"not a number":    {value: "test=two", expectedError: true},
+		"negative weight": {value: "test=-1", expectedError: true},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			weights, err := ParseMarkWeights(tt.value)
+			if tt.expectedError {
+				r.Error(err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tt.expected, weights)
+		})
+	}
+}
+
+func TestMarkWeights_Resolve(t *testing.T) {
+	lessons := []*LessonInfo{
+		{Marks: parseMarks("8 įsk", "Kontrolinis darbas")},
+		{Marks: parseMarks("9", "Namų darbai")},
+		{Marks: parseMarks("10", "")},
+	}
+
+	MarkWeights{"test": 2, "": 0.5}.Resolve(lessons)
+
+	require.Equal(t, [][]float64{{2, 0}, {1}, {0.5}}, lo.Map(lessons, func(item *LessonInfo, _ int) []float64 {
+		return lo.Map(item.Marks, func(m Mark, _ int) float64 {
+			return m.Weight
+		})
+	}))
+}
+
+func TestComputeStats(t *testing.T) {
+	day := func(month time.Month, d int) *time.Time {
+		year := 2024
+		if month < time.September {
+			year = 2025
+		}
+		result := time.Date(year, month, d, 8, 0, 0, 0, time.UTC)
+		return &result
+	}
+	lessons := []*LessonInfo{
+		{Discipline: "Matematika", Day: day(time.December, 16), Marks: parseMarks("8", "Kontrolinis darbas")},
+		{Discipline: "Matematika", Day: day(time.December, 18), Marks: parseMarks("10 6", "")},
+		{Discipline: "Matematika", Day: day(time.January, 8), Marks: parseMarks("9", "Namų darbai")},
+		{Discipline: "Matematika", Day: day(time.January, 10), Attendance: &Attendance{Code: "n", Kind: Absent}},
+		{Discipline: "Kūno kultūra", Day: day(time.December, 20), Marks: parseMarks("įsk", "")},
+		{Discipline: "Kūno kultūra", Day: day(time.January, 9), Marks: parseMarks("neįsk", "")},
+	}
+
+	tests := map[string]struct {
+		weights  MarkWeights
+		expected Stats
+	}{
+		"plain": {
+			expected: Stats{
+				Average: lo.ToPtr(8.25),
+				Count:   4,
+				Disciplines: []DisciplineStats{
+					{Discipline: "Kūno kultūra", Distribution: map[int]int{}, Passed: 1, Failed: 1, Trend: []MonthAverage{}},
+					{
+						Discipline:   "Matematika",
+						Average:      lo.ToPtr(8.25),
+						Count:        4,
+						Distribution: map[int]int{6: 1, 8: 1, 9: 1, 10: 1},
+						Lowest:       6,
+						Highest:      10,
+						Trend: []MonthAverage{
+							{Month: "2024-12", Average: 8, Count: 3},
+							{Month: "2025-01", Average: 9, Count: 1},
+						},
+					},
+				},
+				Weights: MarkWeights{},
+			},
+		},
+		"weighted": {
+			weights: MarkWeights{"test": 3, "homework": 0},
+			expected: Stats{
+				Average: lo.ToPtr(8.0),
+				Count:   4,
+				Disciplines: []DisciplineStats{
+					{Discipline: "Kūno kultūra", Distribution: map[int]int{}, Passed: 1, Failed: 1, Trend: []MonthAverage{}},
+					{
+						Discipline:   "Matematika",
+						Average:      lo.ToPtr(8.0),
+						Count:        4,
+						Distribution: map[int]int{6: 1, 8: 1, 9: 1, 10: 1},
+						Lowest:       6,
+						Highest:      10,
+						Trend: []MonthAverage{
+							{Month: "2024-12", Average: 8, Count: 3},
+						},
+					},
+				},
+				Weights: MarkWeights{"test": 3, "homework": 0},
+			},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			require.Equal(t, tt.expected, ComputeStats(lessons, tt.weights))
+		})
+	}
+
+	require.Equal(t, Stats{Disciplines: []DisciplineStats{}, Weights: MarkWeights{}}, ComputeStats(nil, nil))
+}
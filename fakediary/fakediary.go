@@ -0,0 +1,164 @@
+// Package fakediary provides an offline stand-in for dienynas.vjg.lt, serving sanitized HTML fixtures. It implements
+// just enough of the diary for the collector: login, marks table, final grades and lesson details.
+package fakediary
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"text/template"
+)
+
+//go:embed fixtures/*.html
+var fixtures embed.FS
+
+var templates = template.Must(template.ParseFS(fixtures, "fixtures/*.html"))
+
+const (
+	User        = "jonas"
+	Password    = "slaptazodis"
+	StudentName = "Jonas Jonaitis"
+	ClassName   = "5d"
+
+	// CurrentSemester is selected in marks page when no semester is requested
+	CurrentSemester = "87"
+
+	sessionCookieName = "PHPSESSID"
+)
+
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// tokenBySession maps session cookie to token issued together with it
+	tokenBySession map[string]string
+	logins         int
+	requests       map[string]int
+	// className is shown in the header after login, ClassName unless changed with SetClassName
+	className string
+}
+
+// NewServer starts a fake diary; it must be closed after use.
+func NewServer() *Server {
+	s := &Server{
+		tokenBySession: map[string]string{},
+		requests:       map[string]int{},
+		className:      ClassName,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.php", s.handleLogin)
+	mux.HandleFunc("/marks.php", s.requireSession(s.handleMarks))
+	mux.HandleFunc("/lessoninfo.php", s.requireSession(s.handleLessonInfo))
+	s.Server = httptest.NewServer(s.countRequests(mux))
+	return s
+}
+
+// Logins returns number of successful logins so far.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// Requests returns number of requests made to given path, e.g. "/marks.php".
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// SetClassName changes the class student is shown in after next login, e.g. one missing in the timetable.
+func (s *Server) SetClassName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.className = name
+}
+
+// ExpireSessions forgets all sessions, as the diary does after a period of inactivity.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenBySession = map[string]string{}
+}
+
+func (s *Server) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		s.mu.Lock()
+		s.requests[request.URL.Path]++
+		s.mu.Unlock()
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func (s *Server) handleLogin(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost || request.PostFormValue("login_u") != User || request.PostFormValue("login_p") != Password {
+		s.render(writer, "login.html", nil)
+		return
+	}
+
+	s.mu.Lock()
+	s.logins++
+	session := fmt.Sprintf("session%d", s.logins)
+	// tokens deliberately don't look like hex IDs, so tests show anonymization finds them by value, not by format
+	token := fmt.Sprintf("Tk%dzQ", s.logins)
+	s.tokenBySession[session] = token
+	className := s.className
+	s.mu.Unlock()
+
+	http.SetCookie(writer, &http.Cookie{Name: sessionCookieName, Value: session, Path: "/"})
+	s.render(writer, "home.html", map[string]string{
+		"StudentName": StudentName,
+		"ClassName":   className,
+		"Token":       token,
+	})
+}
+
+// requireSession serves login form instead of requested page when session cookie or token is not valid.
+func (s *Server) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		cookie, err := request.Cookie(sessionCookieName)
+
+		s.mu.Lock()
+		valid := err == nil && s.tokenBySession[cookie.Value] != "" && s.tokenBySession[cookie.Value] == request.URL.Query().Get("token")
+		s.mu.Unlock()
+
+		if !valid {
+			s.render(writer, "login.html", nil)
+			return
+		}
+		next(writer, request)
+	}
+}
+
+func (s *Server) handleMarks(writer http.ResponseWriter, request *http.Request) {
+	semester := request.URL.Query().Get("semester")
+	if semester == "" {
+		semester = CurrentSemester
+	}
+	name := "marks.html"
+	if request.URL.Query().Get("final") == "1" {
+		name = "final.html"
+	}
+	s.render(writer, name, map[string]string{
+		"Semester": semester,
+	})
+}
+
+func (s *Server) handleLessonInfo(writer http.ResponseWriter, request *http.Request) {
+	name := "lessoninfo_" + request.URL.Query().Get("id") + ".html"
+	if templates.Lookup(name) == nil {
+		http.NotFound(writer, request)
+		return
+	}
+	s.render(writer, name, nil)
+}
+
+func (s *Server) render(writer http.ResponseWriter, name string, data any) {
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(writer, name, data); err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,298 @@
+// Package fakeedupage provides an offline stand-in for the public edupage timetable of the school, serving recorded
+// JSON fixtures.
+package fakeedupage
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// DefaultTimetable is the timetable number published by default for every school year.
+const DefaultTimetable = "48"
+
+// Timetable is a timetable version as listed by edupage.
+type Timetable struct {
+	Num string
+	// DateFrom is the first day the version is valid, "2006-01-02"
+	DateFrom string
+	Hidden   bool
+}
+
+// Holiday is a school break as listed by edupage; dates are "2006-01-02", both inclusive.
+type Holiday struct {
+	Name     string
+	DateFrom string
+	DateTo   string
+}
+
+// Change is a substituted lesson of a class as listed in the daily timetable.
+type Change struct {
+	// Date is the day of the lesson, "2006-01-02"
+	Date      string
+	Period    string
+	StartTime string
+	SubjectID string
+	ClassID   string
+	// Removed marks a cancelled lesson; otherwise teachers and classrooms replace the regular ones
+	Removed      bool
+	TeacherIDs   []string
+	ClassroomIDs []string
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	requests   map[string]int
+	timetables map[int][]Timetable
+	holidays   map[int][]Holiday
+	changes    []Change
+}
+
+// NewServer starts a fake edupage; it must be closed after use.
+func NewServer() *Server {
+	s := &Server{
+		requests:   map[string]int{},
+		timetables: map[int][]Timetable{},
+		holidays:   map[int][]Holiday{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/timetable/server/regulartt.js", s.handleRPC(map[string]rpcFunc{
+		"regularttGetData": s.regularttGetData,
+	}))
+	mux.HandleFunc("/timetable/server/ttviewer.js", s.handleRPC(map[string]rpcFunc{
+		"getTTViewerData": s.getTTViewerData,
+	}))
+	mux.HandleFunc("/rpr/server/maindbi.js", s.handleRPC(map[string]rpcFunc{
+		"mainDBIAccessor": s.mainDBIAccessor,
+	}))
+	mux.HandleFunc("/timetable/server/currenttt.js", s.handleRPC(map[string]rpcFunc{
+		"curentttGetData": s.curentttGetData,
+	}))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Requests returns number of calls made to given edupage function, e.g. "regularttGetData".
+func (s *Server) Requests(function string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[function]
+}
+
+// SetTimetables replaces timetable versions published for the school year starting in given year. By default every
+// year has a single DefaultTimetable version starting on September 1st.
+func (s *Server) SetTimetables(year int, timetables []Timetable) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timetables[year] = timetables
+}
+
+// SetHolidays replaces holidays listed for the school year starting in given year; there are none by default.
+func (s *Server) SetHolidays(year int, holidays []Holiday) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holidays[year] = holidays
+}
+
+// SetChanges replaces substitutions listed in the daily timetable; there are none by default.
+func (s *Server) SetChanges(changes []Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = changes
+}
+
+type rpcFunc func(args []any) (any, error)
+
+// handleRPC serves edupage style calls: POST with function name in __func query parameter and JSON arguments in body.
+func (s *Server) handleRPC(functions map[string]rpcFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		function := request.URL.Query().Get("__func")
+		fn, ok := functions[function]
+		if !ok || request.Method != http.MethodPost {
+			http.NotFound(writer, request)
+			return
+		}
+
+		args := struct {
+			Args []any `json:"__args"`
+		}{}
+		if err := json.NewDecoder(request.Body).Decode(&args); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.requests[function]++
+		s.mu.Unlock()
+
+		result, err := fn(args.Args)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		if contents, ok := result.([]byte); ok {
+			_, _ = writer.Write(contents)
+			return
+		}
+		_ = json.NewEncoder(writer).Encode(result)
+	}
+}
+
+// regularttGetData serves the recorded timetable of the requested number: args are [null, "<tt_num>"].
+func (s *Server) regularttGetData(args []any) (any, error) {
+	num, _ := argAt(args, 1).(string)
+	contents, err := fixtures.ReadFile("fixtures/regulartt_" + num + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown timetable %q", num)
+	}
+	return contents, nil
+}
+
+// getTTViewerData lists timetable versions of a school year: args are [null, <year>].
+func (s *Server) getTTViewerData(args []any) (any, error) {
+	year, ok := argAt(args, 1).(float64)
+	if !ok {
+		return nil, fmt.Errorf("missing year")
+	}
+
+	s.mu.Lock()
+	timetables, ok := s.timetables[int(year)]
+	s.mu.Unlock()
+	if !ok {
+		timetables = []Timetable{{Num: DefaultTimetable, DateFrom: fmt.Sprintf("%d-09-01", int(year))}}
+	}
+
+	type timetable struct {
+		Num      string `json:"tt_num"`
+		Year     int    `json:"year"`
+		Text     string `json:"text"`
+		DateFrom string `json:"datefrom"`
+		Hidden   bool   `json:"hidden"`
+	}
+	result := struct {
+		R struct {
+			Regular struct {
+				DefaultNum string      `json:"default_num"`
+				Timetables []timetable `json:"timetables"`
+			} `json:"regular"`
+		} `json:"r"`
+	}{}
+	result.R.Regular.Timetables = []timetable{}
+	for _, t := range timetables {
+		result.R.Regular.Timetables = append(result.R.Regular.Timetables, timetable{
+			Num:      t.Num,
+			Year:     int(year),
+			Text:     "Tvarkaraštis nuo " + t.DateFrom,
+			DateFrom: t.DateFrom,
+			Hidden:   t.Hidden,
+		})
+		if !t.Hidden {
+			result.R.Regular.DefaultNum = t.Num
+		}
+	}
+	return result, nil
+}
+
+// mainDBIAccessor serves school year data; only holidays are supported: args are [null, <year>, {}, {...}].
+func (s *Server) mainDBIAccessor(args []any) (any, error) {
+	year, ok := argAt(args, 1).(float64)
+	if !ok {
+		return nil, fmt.Errorf("missing year")
+	}
+
+	s.mu.Lock()
+	holidays := s.holidays[int(year)]
+	s.mu.Unlock()
+
+	type row struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		DateFrom string `json:"datefrom"`
+		DateTo   string `json:"dateto"`
+	}
+	type table struct {
+		ID       string `json:"id"`
+		DataRows []row  `json:"data_rows"`
+	}
+	rows := []row{}
+	for i, h := range holidays {
+		rows = append(rows, row{ID: fmt.Sprint(-(i + 1)), Name: h.Name, DateFrom: h.DateFrom, DateTo: h.DateTo})
+	}
+	result := struct {
+		R struct {
+			Tables []table `json:"tables"`
+		} `json:"r"`
+	}{}
+	result.R.Tables = []table{{ID: "holidays", DataRows: rows}}
+	return result, nil
+}
+
+// curentttGetData lists changed lessons of a class: args are [null, {"table": "classes", "id": ..., "datefrom": ...,
+// "dateto": ...}]. Unlike edupage, regular lessons are not listed.
+func (s *Server) curentttGetData(args []any) (any, error) {
+	params, ok := argAt(args, 1).(map[string]any)
+	if !ok || params["table"] != "classes" {
+		return nil, fmt.Errorf("missing class")
+	}
+	classID, _ := params["id"].(string)
+	from, _ := params["datefrom"].(string)
+	to, _ := params["dateto"].(string)
+
+	s.mu.Lock()
+	changes := s.changes
+	s.mu.Unlock()
+
+	type item struct {
+		Type         string   `json:"type"`
+		Date         string   `json:"date"`
+		Period       string   `json:"uniperiod"`
+		StartTime    string   `json:"starttime"`
+		SubjectID    string   `json:"subjectid"`
+		ClassIDs     []string `json:"classids"`
+		TeacherIDs   []string `json:"teacherids"`
+		ClassroomIDs []string `json:"classroomids"`
+		Removed      bool     `json:"removed,omitempty"`
+		Changed      bool     `json:"changed,omitempty"`
+	}
+	result := struct {
+		R struct {
+			Items []item `json:"ttitems"`
+		} `json:"r"`
+	}{}
+	result.R.Items = []item{}
+	for _, c := range changes {
+		if c.ClassID != classID || c.Date < from || c.Date > to {
+			continue
+		}
+		result.R.Items = append(result.R.Items, item{
+			Type:         "card",
+			Date:         c.Date,
+			Period:       c.Period,
+			StartTime:    c.StartTime,
+			SubjectID:    c.SubjectID,
+			ClassIDs:     []string{c.ClassID},
+			TeacherIDs:   c.TeacherIDs,
+			ClassroomIDs: c.ClassroomIDs,
+			Removed:      c.Removed,
+			Changed:      !c.Removed,
+		})
+	}
+	return result, nil
+}
+
+func argAt(args []any, i int) any {
+	if i >= len(args) {
+		return nil
+	}
+	return args[i]
+}
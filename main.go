@@ -2,6 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -9,6 +18,15 @@ import (
 )
 
 func main() {
+	// standalone mode for self-hosting; Lambda runtime does not set LISTEN_ADDR
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		if err := serveStandalone(addr, os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")); err != nil {
+			fmt.Fprintf(os.Stderr, "server failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	lambda.StartWithOptions(BuildHandler())
 }
 
@@ -20,3 +38,68 @@ func BuildHandler() func(ctx context.Context, event events.APIGatewayV2HTTPReque
 	adapter := gorillamux.NewV2(s)
 	return adapter.ProxyWithContext
 }
+
+// serveStandalone runs the same router as Lambda on a plain HTTP(S) server until SIGINT or SIGTERM is received,
+// then lets in-flight requests finish. TLS is used when both certificate and key files are given.
+func serveStandalone(addr string, certFile string, keyFile string) error {
+	// a temporary key would log everyone out on each restart of a long running server
+	if os.Getenv("SESSION_KEYS") == "" {
+		return errors.New("SESSION_KEYS must be set in standalone mode")
+	}
+
+	s, err := BuildServer()
+	if err != nil {
+		return fmt.Errorf("building server: %w", err)
+	}
+
+	// signals are caught before listening, so that a server accepting connections can always be stopped gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serve(ctx, newHTTPServer(s), listener, certFile, keyFile)
+}
+
+func newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// schedule download alone can take up to a minute
+		WriteTimeout: 90 * time.Second,
+		IdleTimeout:  2 * time.Minute,
+	}
+}
+
+// serve accepts connections on listener until ctx is done, then shuts srv down gracefully.
+func serve(ctx context.Context, srv *http.Server, listener net.Listener, certFile string, keyFile string) error {
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", listener.Addr())
+		if certFile != "" && keyFile != "" {
+			errs <- srv.ServeTLS(listener, certFile, keyFile)
+		} else {
+			errs <- srv.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), srv.WriteTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
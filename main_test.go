@@ -2,13 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/collector"
+	"vjgdienynas/fakediary"
+	"vjgdienynas/fakeedupage"
+	"vjgdienynas/session"
 )
 
 func TestHandler(t *testing.T) {
@@ -61,6 +77,376 @@ func TestHandler(t *testing.T) {
 	println(lessonInfoResult.Body)
 }
 
+func TestHandler_fakeDiary(t *testing.T) {
+	r := require.New(t)
+	diary := fakediary.NewServer()
+	defer diary.Close()
+
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+	edupage.SetHolidays(2024, []fakeedupage.Holiday{{Name: "Rudens atostogos", DateFrom: "2024-10-28", DateTo: "2024-11-03"}})
+
+	t.Setenv("HOLIDAYS", "2025-02-17")
+	t.Setenv("MARK_WEIGHTS", "activity=3")
+	t.Setenv("DIARY_URL", diary.URL)
+	t.Setenv("EDUPAGE_URL", edupage.URL)
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("CACHE_BUCKET", "")
+
+	handler := BuildHandler()
+	call := func(method string, path string, cookies []string, body string) events.APIGatewayV2HTTPResponse {
+		t.Helper()
+		path, query, _ := strings.Cut(path, "?")
+		result, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+			RawPath:        path,
+			RawQueryString: query,
+			Cookies:        cookies,
+			RequestContext: events.APIGatewayV2HTTPRequestContext{
+				HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+					Method: method,
+				},
+			},
+			Body: body,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	wrongLogin := call("POST", "/api/login", nil, toJSON(t, &LoginRequest{Username: fakediary.User, Password: "wrong"}))
+	r.Equal(http.StatusForbidden, wrongLogin.StatusCode)
+
+	loginResult := call("POST", "/api/login", nil, toJSON(t, &LoginRequest{Username: fakediary.User, Password: fakediary.Password}))
+	r.Equal(http.StatusOK, loginResult.StatusCode)
+	r.JSONEq(`{"name":"`+fakediary.StudentName+`","class":"`+fakediary.ClassName+`"}`, loginResult.Body)
+	cookies := requestCookies(loginResult.Cookies)
+
+	loggedIn := call("GET", "/api/login", cookies, "")
+	r.Equal(http.StatusOK, loggedIn.StatusCode)
+
+	lessonInfoResult := call("GET", "/api/lesson-info", cookies, "")
+	r.Equal(http.StatusOK, lessonInfoResult.StatusCode, lessonInfoResult.Body)
+	var lessonInfos struct {
+		Lessons       []collector.LessonInfo `json:"lessons"`
+		FailedLessons []map[string]string    `json:"failedLessons"`
+	}
+	r.NoError(json.Unmarshal([]byte(lessonInfoResult.Body), &lessonInfos))
+	lessons := lessonInfos.Lessons
+	// absence marked on a day without a lesson entry is only counted in attendance
+	r.Len(lessons, 4)
+	r.Equal([]map[string]string{{"lessonId": "2002", "error": "unexpected status 404"}}, lessonInfos.FailedLessons)
+	for _, l := range lessons {
+		r.NotEmpty(l.NextDates, "lesson %s should be matched with schedule", l.ID)
+	}
+	math, _ := lo.Find(lessons, func(item collector.LessonInfo) bool {
+		return item.ID == "1001"
+	})
+	r.Equal("Petras Petraitis", math.Teacher)
+	r.Equal("9", math.Mark)
+	r.Equal(3.0, math.Marks[0].Weight, "mark weight should be resolved from MARK_WEIGHTS")
+	tale, _ := lo.Find(lessons, func(item collector.LessonInfo) bool {
+		return item.ID == "2001"
+	})
+	r.Len(tale.Homework, 1)
+	r.Equal(collector.DueHigh, tale.Homework[0].Confidence)
+	r.Equal("2025-01-10", tale.Homework[0].Due.Format(time.DateOnly))
+	r.Equal(1, diary.Logins(), "diary session should be reused after login")
+
+	semestersResult := call("GET", "/api/semesters", cookies, "")
+	r.Equal(http.StatusOK, semestersResult.StatusCode)
+	r.Contains(semestersResult.Body, "2024-2025 m. m. I pusmetis")
+
+	// expired diary session is renewed transparently and stored in the session cookie
+	diary.ExpireSessions()
+	renewed := call("GET", "/api/lesson-info", cookies, "")
+	r.Equal(http.StatusOK, renewed.StatusCode)
+	r.Equal(2, diary.Logins())
+	r.NotEmpty(renewed.Cookies)
+	cookies = requestCookies(renewed.Cookies)
+
+	r.Equal(http.StatusOK, call("GET", "/api/lesson-info", cookies, "").StatusCode)
+	r.Equal(2, diary.Logins())
+
+	unknownSemester := call("GET", "/api/lesson-info?semester=1", cookies, "")
+	r.Equal(http.StatusBadRequest, unknownSemester.StatusCode)
+
+	classes := call("GET", "/api/classes", nil, "")
+	r.Equal(http.StatusOK, classes.StatusCode)
+	r.JSONEq(`[{"id":"-10","name":"5d","short":"5d"},{"id":"-11","name":"6a","short":"6a"}]`, classes.Body)
+
+	r.Equal(http.StatusBadRequest, call("POST", "/api/class", cookies, `{"class":"9z"}`).StatusCode)
+	picked := call("POST", "/api/class", cookies, `{"class":"6A"}`)
+	r.Equal(http.StatusOK, picked.StatusCode)
+	r.JSONEq(`{"name":"`+fakediary.StudentName+`","class":"6a"}`, picked.Body)
+	cookies = requestCookies(picked.Cookies)
+	r.JSONEq(`{"name":"`+fakediary.StudentName+`","class":"6a"}`, call("GET", "/api/login", cookies, "").Body)
+
+	reverted := call("POST", "/api/class", cookies, `{"class":""}`)
+	r.JSONEq(`{"name":"`+fakediary.StudentName+`","class":"`+fakediary.ClassName+`"}`, reverted.Body)
+	cookies = requestCookies(reverted.Cookies)
+
+	// diary has neither of the language group subjects, so nothing can be inferred
+	groups := call("GET", "/api/groups", cookies, "")
+	r.Equal(http.StatusOK, groups.StatusCode, groups.Body)
+	r.JSONEq(`{"divisions":[["1 grupė","2 grupė"]],"groups":[],"inferred":true}`, groups.Body)
+
+	r.Equal(http.StatusBadRequest, call("POST", "/api/groups", cookies, `{"groups":["3 grupė"]}`).StatusCode)
+	pickedGroups := call("POST", "/api/groups", cookies, `{"groups":["2 grupė"]}`)
+	r.Equal(http.StatusOK, pickedGroups.StatusCode, pickedGroups.Body)
+	r.JSONEq(`{"divisions":[["1 grupė","2 grupė"]],"groups":["2 grupė"],"inferred":false}`, pickedGroups.Body)
+	cookies = requestCookies(pickedGroups.Cookies)
+	r.JSONEq(pickedGroups.Body, call("GET", "/api/groups", cookies, "").Body)
+	r.Equal(http.StatusOK, call("GET", "/api/lesson-info", cookies, "").StatusCode)
+
+	report := call("GET", "/api/diagnostics/disciplines", cookies, "")
+	r.Equal(http.StatusOK, report.StatusCode, report.Body)
+	r.JSONEq(`{
+		"matched":[
+			{"subject":"Matematika","short":"Mat","discipline":"Matematika","kind":"exact"},
+			{"subject":"Lietuvių k.","short":"Lt","discipline":"Lietuvių kalba ir literatūra","kind":"mapped"}
+		],
+		"fuzzy":[],
+		"unmatchedSubjects":["Vokiečių k.","Prancūzų k."],
+		"unmatchedDisciplines":[]
+	}`, report.Body)
+
+	r.Equal(http.StatusBadRequest, call("GET", "/api/diagnostics/disciplines?semester=1", cookies, "").StatusCode)
+
+	attendance := call("GET", "/api/attendance", cookies, "")
+	r.Equal(http.StatusOK, attendance.StatusCode, attendance.Body)
+	r.JSONEq(`{
+		"total":{"absent":2,"late":1,"justified":1,"unjustified":1},
+		"disciplines":[
+			{"discipline":"Lietuvių kalba ir literatūra","absent":1,"late":1,"justified":1,"unjustified":0},
+			{"discipline":"Matematika","absent":1,"late":0,"justified":0,"unjustified":1}
+		],
+		"weeks":[
+			{"week":"2024-12-16","absent":0,"late":1,"justified":0,"unjustified":0},
+			{"week":"2025-01-06","absent":2,"late":0,"justified":1,"unjustified":1}
+		]
+	}`, attendance.Body)
+	r.Equal(http.StatusBadRequest, call("GET", "/api/attendance?semester=1", cookies, "").StatusCode)
+
+	stats := call("GET", "/api/stats?weights=activity=2", cookies, "")
+	r.Equal(http.StatusOK, stats.StatusCode, stats.Body)
+	r.JSONEq(`{
+		"average":9,
+		"count":1,
+		"disciplines":[
+			{"discipline":"Matematika","average":9,"count":1,"distribution":{"9":1},"passed":0,"failed":0,
+				"lowest":9,"highest":9,"trend":[{"month":"2024-12","average":9,"count":1}]}
+		],
+		"weights":{"activity":2}
+	}`, stats.Body)
+	r.Equal(http.StatusBadRequest, call("GET", "/api/stats?weights=test", cookies, "").StatusCode)
+
+	finalGrades := call("GET", "/api/final-grades?semester=86", cookies, "")
+	r.Equal(http.StatusOK, finalGrades.StatusCode, finalGrades.Body)
+	r.JSONEq(`{
+		"semester":{"id":"86","label":"2023-2024 m. m. II pusmetis","from":"2024-02-01T00:00:00Z","to":"2024-08-31T00:00:00Z"},
+		"disciplines":[
+			{"discipline":"Matematika","grades":[
+				{"label":"I pusmetis","kind":"semester","semester":1,"raw":"8","marks":[{"raw":"8","kind":"numeric","value":8,"weight":1}]},
+				{"label":"II pusmetis","kind":"semester","semester":2,"raw":"9","marks":[{"raw":"9","kind":"numeric","value":9,"weight":1}]},
+				{"label":"Metinis","kind":"annual","raw":"9","marks":[{"raw":"9","kind":"numeric","value":9,"weight":1}]},
+				{"label":"Egzaminas","kind":"exam","raw":"10","marks":[{"raw":"10","kind":"numeric","value":10,"weight":1}]}
+			]},
+			{"discipline":"Lietuvių kalba ir literatūra","grades":[
+				{"label":"I pusmetis","kind":"semester","semester":1,"raw":"7","marks":[{"raw":"7","kind":"numeric","value":7,"weight":1}]},
+				{"label":"II pusmetis","kind":"semester","semester":2,"raw":"8","marks":[{"raw":"8","kind":"numeric","value":8,"weight":1}]},
+				{"label":"Metinis","kind":"annual","raw":"8","marks":[{"raw":"8","kind":"numeric","value":8,"weight":1}]}
+			]}
+		]
+	}`, finalGrades.Body)
+	r.Equal(http.StatusBadRequest, call("GET", "/api/final-grades?semester=1", cookies, "").StatusCode)
+
+	calendar := call("GET", "/api/calendar?year=2024", nil, "")
+	r.Equal(http.StatusOK, calendar.StatusCode, calendar.Body)
+	r.JSONEq(`{"from":"2024-09-01","to":"2025-08-31","holidays":[
+		{"name":"Rudens atostogos","from":"2024-10-28","to":"2024-11-03","source":"edupage"},
+		{"name":"","from":"2025-02-17","to":"2025-02-17","source":"config"}
+	]}`, calendar.Body)
+	r.Equal(http.StatusBadRequest, call("GET", "/api/calendar?year=next", nil, "").StatusCode)
+}
+
+func TestHandler_classMissingInTimetable(t *testing.T) {
+	r := require.New(t)
+	diary := fakediary.NewServer()
+	defer diary.Close()
+	diary.SetClassName("9z")
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+
+	t.Setenv("DIARY_URL", diary.URL)
+	t.Setenv("EDUPAGE_URL", edupage.URL)
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("CACHE_BUCKET", "")
+
+	handler := BuildHandler()
+	request := func(method string, path string, cookies []string, body string) events.APIGatewayV2HTTPRequest {
+		return events.APIGatewayV2HTTPRequest{
+			RawPath: path,
+			Cookies: cookies,
+			RequestContext: events.APIGatewayV2HTTPRequestContext{
+				HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: method},
+			},
+			Body: body,
+		}
+	}
+
+	login, err := handler(context.Background(), request("POST", "/api/login", nil, toJSON(t, &LoginRequest{Username: fakediary.User, Password: fakediary.Password})))
+	r.NoError(err)
+	r.Equal(http.StatusOK, login.StatusCode)
+	r.JSONEq(`{"name":"`+fakediary.StudentName+`","class":"9z"}`, login.Body)
+
+	// lessons are still listed, just without dates from the timetable
+	lessonInfo, err := handler(context.Background(), request("GET", "/api/lesson-info", requestCookies(login.Cookies), ""))
+	r.NoError(err)
+	r.Equal(http.StatusOK, lessonInfo.StatusCode, lessonInfo.Body)
+	var response struct {
+		Lessons []collector.LessonInfo `json:"lessons"`
+	}
+	r.NoError(json.Unmarshal([]byte(lessonInfo.Body), &response))
+	r.NotEmpty(response.Lessons)
+	for _, l := range response.Lessons {
+		r.Empty(l.NextDates, "lesson %s", l.ID)
+	}
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	r := require.New(t)
+	t.Setenv("CACHE_DIR", t.TempDir())
+	s, err := BuildServer()
+	r.NoError(err)
+
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = newHTTPServer(s)
+	ts.StartTLS()
+	defer ts.Close()
+	client := ts.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Get(ts.URL + "/login")
+	r.NoError(err)
+	resp.Body.Close()
+	r.Equal(http.StatusFound, resp.StatusCode)
+	r.Equal("/", resp.Header.Get("Location"))
+
+	resp, err = client.Get(ts.URL + "/api/login")
+	r.NoError(err)
+	resp.Body.Close()
+	r.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestServe_finishesInFlightRequests(t *testing.T) {
+	r := require.New(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-release
+		_, _ = writer.Write([]byte("done"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(ctx, newHTTPServer(handler), listener, "", "")
+	}()
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-errs:
+		r.Failf("server stopped before request finished", "%v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	r.Equal("done", <-responses)
+	r.NoError(<-errs)
+}
+
+func TestServeStandalone_requiresSessionKeys(t *testing.T) {
+	t.Setenv("SESSION_KEYS", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+
+	require.ErrorContains(t, serveStandalone("127.0.0.1:0", "", ""), "SESSION_KEYS")
+}
+
+func TestServeStandalone_TLSAndSIGTERM(t *testing.T) {
+	r := require.New(t)
+	t.Setenv("CACHE_DIR", t.TempDir())
+	sessionKey, err := session.GenerateKey()
+	r.NoError(err)
+	t.Setenv("SESSION_KEYS", base64.StdEncoding.EncodeToString(sessionKey))
+
+	// certificate of httptest, trusted by its client, is reused as the server certificate
+	certSource := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certSource.Close()
+	cert := certSource.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	r.NoError(err)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	r.NoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	r.NoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	addr := free.Addr().String()
+	r.NoError(free.Close())
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serveStandalone(addr, certFile, keyFile)
+	}()
+
+	client := certSource.Client()
+	r.Eventually(func() bool {
+		resp, err := client.Get("https://" + addr + "/_app/env.js")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 20*time.Millisecond)
+
+	process, err := os.FindProcess(os.Getpid())
+	r.NoError(err)
+	r.NoError(process.Signal(syscall.SIGTERM))
+	select {
+	case err := <-errs:
+		r.NoError(err)
+	case <-time.After(5 * time.Second):
+		r.Fail("server did not stop on SIGTERM")
+	}
+}
+
+// requestCookies converts Set-Cookie values of a response to cookies for the next request.
+func requestCookies(setCookies []string) []string {
+	return lo.Map(setCookies, func(item string, _ int) string {
+		nameValue, _, _ := strings.Cut(item, ";")
+		return nameValue
+	})
+}
+
 func toJSON(t testing.TB, v interface{}) string {
 	t.Helper()
 	result, err := json.Marshal(v)
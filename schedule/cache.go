@@ -0,0 +1,188 @@
+package schedule
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Cache keeps downloaded files between runs. Read reports missing or expired files with an error wrapping
+// fs.ErrNotExist. Names may contain slashes, see NamespacedCache.
+type Cache interface {
+	Read(ctx context.Context, name string) ([]byte, error)
+	Write(ctx context.Context, name string, contents []byte) error
+}
+
+// CacheConfig describes which cache to use and how.
+type CacheConfig struct {
+	// Backend is "s3", "dir", "memory", or empty for no cache
+	Backend string
+	// Bucket is the S3 bucket of "s3" backend
+	Bucket string
+	// Dir is the directory of "dir" backend
+	Dir string
+	// Compress stores files gzipped
+	Compress bool
+	// Namespace is prepended to file names, so that several schools or deployments can share a bucket or directory
+	Namespace string
+}
+
+// CacheConfigFromEnv reads cache configuration: CACHE_BACKEND, CACHE_BUCKET, CACHE_DIR, CACHE_COMPRESS and
+// CACHE_NAMESPACE. Without CACHE_BACKEND, backend is picked by whichever of CACHE_BUCKET and CACHE_DIR is set.
+func CacheConfigFromEnv() CacheConfig {
+	config := CacheConfig{
+		Backend:   os.Getenv("CACHE_BACKEND"),
+		Bucket:    os.Getenv("CACHE_BUCKET"),
+		Dir:       os.Getenv("CACHE_DIR"),
+		Compress:  os.Getenv("CACHE_COMPRESS") == "true",
+		Namespace: os.Getenv("CACHE_NAMESPACE"),
+	}
+	if config.Backend == "" {
+		switch {
+		case config.Bucket != "":
+			config.Backend = "s3"
+		case config.Dir != "":
+			config.Backend = "dir"
+		}
+	}
+	return config
+}
+
+// NewCache creates the configured cache; it's nil when no backend is configured.
+func NewCache(config CacheConfig) (Cache, error) {
+	var cache Cache
+	switch config.Backend {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		cache = NewMemoryCache()
+	case "dir":
+		if config.Dir == "" {
+			return nil, fmt.Errorf("dir cache: directory not set")
+		}
+		dirCache, err := NewDirCache(config.Dir)
+		if err != nil {
+			return nil, err
+		}
+		cache = dirCache
+	case "s3":
+		if config.Bucket == "" {
+			return nil, fmt.Errorf("s3 cache: bucket not set")
+		}
+		s3Cache, err := NewS3Cache(config.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		cache = s3Cache
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", config.Backend)
+	}
+
+	if config.Compress {
+		cache = CompressedCache{Cache: cache}
+	}
+	if config.Namespace != "" {
+		cache = NamespacedCache{Cache: cache, Namespace: config.Namespace}
+	}
+	return cache, nil
+}
+
+// schoolNamespace names the school of an edupage address for cache namespacing, e.g. "vjg.edupage.org".
+func schoolNamespace(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ReplaceAll(u.Host, ":", "_")
+}
+
+// MemoryCache keeps files in memory, for tests and single process runs that can live without a persistent cache.
+type MemoryCache struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{files: map[string][]byte{}}
+}
+
+func (c *MemoryCache) Read(_ context.Context, name string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	contents, ok := c.files[name]
+	if !ok {
+		return nil, fmt.Errorf("reading %s: %w", name, fs.ErrNotExist)
+	}
+	return bytes.Clone(contents), nil
+}
+
+func (c *MemoryCache) Write(_ context.Context, name string, contents []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[name] = bytes.Clone(contents)
+	return nil
+}
+
+// CompressedCache gzips files written to the underlying cache. Files written uncompressed, e.g. before compression was
+// turned on, are still read as is.
+type CompressedCache struct {
+	Cache
+}
+
+func (c CompressedCache) Write(ctx context.Context, name string, contents []byte) error {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(contents); err != nil {
+		return fmt.Errorf("compressing %s: %w", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("compressing %s: %w", name, err)
+	}
+	return c.Cache.Write(ctx, name, buf.Bytes())
+}
+
+func (c CompressedCache) Read(ctx context.Context, name string) ([]byte, error) {
+	contents, err := c.Cache.Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// gzip magic number
+	if !bytes.HasPrefix(contents, []byte{0x1f, 0x8b}) {
+		return contents, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(contents))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing %s: %w", name, err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	result, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// NamespacedCache keeps files of the underlying cache under Namespace "directory".
+type NamespacedCache struct {
+	Cache
+	Namespace string
+}
+
+func (c NamespacedCache) Read(ctx context.Context, name string) ([]byte, error) {
+	return c.Cache.Read(ctx, path.Join(c.Namespace, name))
+}
+
+func (c NamespacedCache) Write(ctx context.Context, name string, contents []byte) error {
+	return c.Cache.Write(ctx, path.Join(c.Namespace, name), contents)
+}
@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/fakeedupage"
+)
+
+func TestNewCache(t *testing.T) {
+	tests := map[string]struct {
+		config CacheConfig
+	}{
+		"memory":            {config: CacheConfig{Backend: "memory"}},
+		"dir":               {config: CacheConfig{Backend: "dir"}},
+		"compressed":        {config: CacheConfig{Backend: "memory", Compress: true}},
+		"namespaced dir":    {config: CacheConfig{Backend: "dir", Namespace: "vjg.edupage.org"}},
+		"compressed in dir": {config: CacheConfig{Backend: "dir", Compress: true, Namespace: "vjg.edupage.org"}},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+			if tt.config.Backend == "dir" {
+				tt.config.Dir = t.TempDir()
+			}
+			c, err := NewCache(tt.config)
+			r.NoError(err)
+
+			_, err = c.Read(ctx, "schedule-48.json")
+			r.ErrorIs(err, fs.ErrNotExist)
+
+			r.NoError(c.Write(ctx, "schedule-48.json", []byte(`{"r":{}}`)))
+			contents, err := c.Read(ctx, "schedule-48.json")
+			r.NoError(err)
+			r.Equal(`{"r":{}}`, string(contents))
+		})
+	}
+
+	c, err := NewCache(CacheConfig{})
+	require.NoError(t, err)
+	require.Nil(t, c)
+
+	_, err = NewCache(CacheConfig{Backend: "redis"})
+	require.Error(t, err)
+	_, err = NewCache(CacheConfig{Backend: "dir"})
+	require.Error(t, err, "dir backend needs a directory")
+}
+
+func TestCompressedCache(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	memory := NewMemoryCache()
+	c := CompressedCache{Cache: memory}
+
+	r.NoError(c.Write(ctx, "compressed.json", []byte(`{"r":{}}`)))
+	raw, err := memory.Read(ctx, "compressed.json")
+	r.NoError(err)
+	r.NotEqual(`{"r":{}}`, string(raw))
+
+	r.NoError(memory.Write(ctx, "plain.json", []byte(`{"r":{}}`)))
+	contents, err := c.Read(ctx, "plain.json")
+	r.NoError(err)
+	r.Equal(`{"r":{}}`, string(contents), "files written before compression was turned on should stay readable")
+}
+
+func TestNamespacedCache(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	memory := NewMemoryCache()
+	first := NamespacedCache{Cache: memory, Namespace: "first.edupage.org"}
+	second := NamespacedCache{Cache: memory, Namespace: "second.edupage.org"}
+
+	r.NoError(first.Write(ctx, "schedule-48.json", []byte("first")))
+	_, err := second.Read(ctx, "schedule-48.json")
+	r.ErrorIs(err, fs.ErrNotExist)
+	contents, err := memory.Read(ctx, "first.edupage.org/schedule-48.json")
+	r.NoError(err)
+	r.Equal("first", string(contents))
+}
+
+func TestCacheConfigFromEnv(t *testing.T) {
+	tests := map[string]struct {
+		env      map[string]string
+		expected CacheConfig
+	}{
+		"nothing":  {expected: CacheConfig{}},
+		"bucket":   {env: map[string]string{"CACHE_BUCKET": "cache"}, expected: CacheConfig{Backend: "s3", Bucket: "cache"}},
+		"dir":      {env: map[string]string{"CACHE_DIR": ".cache"}, expected: CacheConfig{Backend: "dir", Dir: ".cache"}},
+		"explicit": {env: map[string]string{"CACHE_BACKEND": "memory", "CACHE_BUCKET": "cache", "CACHE_COMPRESS": "true", "CACHE_NAMESPACE": "test"}, expected: CacheConfig{Backend: "memory", Bucket: "cache", Compress: true, Namespace: "test"}},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			for _, name := range []string{"CACHE_BACKEND", "CACHE_BUCKET", "CACHE_DIR", "CACHE_COMPRESS", "CACHE_NAMESPACE"} {
+				t.Setenv(name, tt.env[name])
+			}
+			require.Equal(t, tt.expected, CacheConfigFromEnv())
+		})
+	}
+}
+
+func TestDownloader_namespacedCache(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+	r.Equal("vjg.edupage.org", schoolNamespace(DefaultBaseURL))
+
+	dir := t.TempDir()
+	t.Setenv("CACHE_BACKEND", "")
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", dir)
+	t.Setenv("CACHE_COMPRESS", "true")
+	t.Setenv("CACHE_NAMESPACE", "")
+	d, err := NewDownloader(WithBaseURL(edupage.URL))
+	r.NoError(err)
+	_, err = d.GetSchedule(ctx)
+	r.NoError(err)
+	r.FileExists(filepath.Join(dir, schoolNamespace(edupage.URL), "schedule-48.json"), "cache should be namespaced by school")
+
+	// another downloader sharing the cache picks schedule up from there
+	d, err = NewDownloader(WithBaseURL(edupage.URL))
+	r.NoError(err)
+	_, err = d.GetSchedule(ctx)
+	r.NoError(err)
+	r.Equal(1, edupage.Requests("regularttGetData"))
+}
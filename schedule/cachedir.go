@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// dirCacheMaxAge matches expiration rule of the S3 cache bucket. Freshness of a schedule is decided by the downloader,
+// expiration only cleans up the ones no longer used.
+const dirCacheMaxAge = 30 * 24 * time.Hour
+
+// DirCache stores cached files in a local directory, for running without AWS.
+type DirCache struct {
+	dir string
+}
+
+func NewDirCache(dir string) (*DirCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &DirCache{dir: dir}, nil
+}
+
+func (c *DirCache) Write(_ context.Context, name string, contents []byte) error {
+	path := filepath.Join(c.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	// write to temporary file first so that concurrent readers never see partially written contents
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(contents); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *DirCache) Read(_ context.Context, name string) ([]byte, error) {
+	path := filepath.Join(c.dir, filepath.FromSlash(name))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file info: %w", err)
+	}
+	if time.Since(info.ModTime()) > dirCacheMaxAge {
+		return nil, fmt.Errorf("cache file expired: %w", fs.ErrNotExist)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return contents, nil
+}
@@ -0,0 +1,38 @@
+package schedule
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirCache(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := NewDirCache(dir)
+	r.NoError(err)
+
+	_, err = c.Read(ctx, "schedule.json")
+	r.ErrorIs(err, fs.ErrNotExist)
+
+	r.NoError(c.Write(ctx, "schedule.json", []byte("{}")))
+	contents, err := c.Read(ctx, "schedule.json")
+	r.NoError(err)
+	r.Equal("{}", string(contents))
+
+	r.NoError(c.Write(ctx, "vjg.edupage.org/schedule.json", []byte("[]")))
+	contents, err = c.Read(ctx, "vjg.edupage.org/schedule.json")
+	r.NoError(err)
+	r.Equal("[]", string(contents), "names may contain directories")
+
+	stale := time.Now().Add(-dirCacheMaxAge - time.Minute)
+	r.NoError(os.Chtimes(filepath.Join(dir, "schedule.json"), stale, stale))
+	_, err = c.Read(ctx, "schedule.json")
+	r.ErrorIs(err, fs.ErrNotExist)
+}
@@ -3,34 +3,38 @@ package schedule
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-type Cache struct {
+// S3Cache stores cached files in an S3 bucket.
+type S3Cache struct {
 	svc    *s3.Client
 	bucket string
 }
 
-func NewCache(bucket string) (*Cache, error) {
+func NewS3Cache(bucket string) (*S3Cache, error) {
 	cfg, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("unable to load SDK config: %w", err)
 	}
 
 	svc := s3.NewFromConfig(cfg)
-	return &Cache{
+	return &S3Cache{
 		bucket: bucket,
 		svc:    svc,
 	}, nil
 }
 
-func (c *Cache) Write(ctx context.Context, name string, contents []byte) error {
-	// bucket will be configured with expiration
+func (c *S3Cache) Write(ctx context.Context, name string, contents []byte) error {
+	// bucket expires objects that are no longer used; fresh ones are picked by Downloader
 	_, err := c.svc.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(c.bucket),
 		Key:    aws.String(name),
@@ -39,11 +43,16 @@ func (c *Cache) Write(ctx context.Context, name string, contents []byte) error {
 	return err
 }
 
-func (c *Cache) Read(ctx context.Context, name string) ([]byte, error) {
+func (c *S3Cache) Read(ctx context.Context, name string) ([]byte, error) {
 	resp, err := c.svc.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(c.bucket),
 		Key:    aws.String(name),
 	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		// bucket lifecycle rule has expired the object
+		return nil, fmt.Errorf("getting object: %w", fs.ErrNotExist)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("getting object: %w", err)
 	}
@@ -0,0 +1,396 @@
+package schedule
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const holidaysLocation = "/rpr/server/maindbi.js?__func=mainDBIAccessor"
+
+// Holiday is a range of non-teaching days, e.g. a school break. Dates are "2006-01-02", both ends inclusive.
+type Holiday struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Source tells where holiday comes from: "edupage" or "config"
+	Source string `json:"source"`
+}
+
+// Calendar lists non-teaching days of a period.
+type Calendar struct {
+	// From and To are the first and the last day of the period
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Holidays []Holiday `json:"holidays"`
+}
+
+// IsHoliday reports whether t falls on a non-teaching day, judging by the date in school's time zone.
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	if c == nil || len(c.Holidays) == 0 {
+		return false
+	}
+	loc, err := schoolLocation()
+	if err != nil {
+		return false
+	}
+	day := t.In(loc).Format(time.DateOnly)
+	for _, h := range c.Holidays {
+		if h.From <= day && day <= h.To {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether holiday shares at least a day with [from, to] dates.
+func (h Holiday) overlaps(from string, to string) bool {
+	return h.From <= to && from <= h.To
+}
+
+// ParseHolidayList parses comma separated list of days or day ranges, e.g. "2024-10-28..2024-11-03,2025-02-17".
+func ParseHolidayList(value string) ([]Holiday, error) {
+	var result []Holiday
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "..")
+		if !isRange {
+			to = from
+		}
+		h := Holiday{From: strings.TrimSpace(from), To: strings.TrimSpace(to), Source: "config"}
+		if err := h.validate(); err != nil {
+			return nil, err
+		}
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+func (h Holiday) validate() error {
+	from, err := time.Parse(time.DateOnly, h.From)
+	if err != nil {
+		return fmt.Errorf("holiday %q: %w", h.Name, err)
+	}
+	to, err := time.Parse(time.DateOnly, h.To)
+	if err != nil {
+		return fmt.Errorf("holiday %q: %w", h.Name, err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("holiday %q: ends (%s) before it starts (%s)", h.Name, h.To, h.From)
+	}
+	return nil
+}
+
+// ParseICS reads events of an iCalendar file as holidays. All-day events end the day before DTEND, as DTEND is
+// exclusive there.
+func ParseICS(r io.Reader) ([]Holiday, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Holiday
+	var current *Holiday
+	// endExclusive is set for DTEND that stands for the start of the day after the event
+	var endExclusive bool
+	for i, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				current = &Holiday{Source: "config"}
+				endExclusive = false
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || current == nil {
+				continue
+			}
+			if current.From == "" {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", i+1, current.Name)
+			}
+			if current.To == "" {
+				current.To = current.From
+			} else if endExclusive && current.To > current.From {
+				current.To = addDays(current.To, -1)
+			}
+			if err := current.validate(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			result = append(result, *current)
+			current = nil
+		case "SUMMARY":
+			if current != nil {
+				current.Name = unescapeICS(value)
+			}
+		case "DTSTART", "DTEND":
+			if current == nil {
+				continue
+			}
+			date, startOfDay, err := parseICSDate(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				current.From = date
+			} else {
+				current.To = date
+				endExclusive = startOfDay
+			}
+		}
+	}
+	return result, nil
+}
+
+// unfoldICS joins continuation lines, which start with a space or a tab.
+func unfoldICS(r io.Reader) ([]string, error) {
+	var result []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(result) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			result[len(result)-1] += line[1:]
+			continue
+		}
+		result = append(result, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading calendar: %w", err)
+	}
+	return result, nil
+}
+
+// parseICSDate returns the day of DTSTART/DTEND value in school's time zone; startOfDay tells whether value stands for
+// the very beginning of that day, e.g. it's a date without time.
+func parseICSDate(value string, params string) (string, bool, error) {
+	loc, err := schoolLocation()
+	if err != nil {
+		return "", false, err
+	}
+	if !strings.Contains(value, "T") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid date %q", value)
+		}
+		return t.Format(time.DateOnly), true, nil
+	}
+
+	t, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		if _, tzid, ok := strings.Cut(params, "TZID="); ok {
+			if l, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+				loc = l
+			}
+		}
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid date %q", value)
+		}
+	}
+	t = t.In(loc)
+	return t.Format(time.DateOnly), t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0, nil
+}
+
+func unescapeICS(value string) string {
+	return strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(value)
+}
+
+func addDays(date string, days int) string {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, days).Format(time.DateOnly)
+}
+
+// LoadHolidays reads configured holidays: a list as accepted by ParseHolidayList and an iCalendar file; both optional.
+func LoadHolidays(list string, icsPath string) ([]Holiday, error) {
+	result, err := ParseHolidayList(list)
+	if err != nil {
+		return nil, err
+	}
+	if icsPath == "" {
+		return result, nil
+	}
+
+	f, err := os.Open(icsPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening calendar: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	fromICS, err := ParseICS(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", icsPath, err)
+	}
+	return append(result, fromICS...), nil
+}
+
+// WithHolidays adds non-teaching days to the ones published in edupage, for breaks school does not list there.
+func WithHolidays(holidays []Holiday) Option {
+	return func(d *Downloader) {
+		d.holidays = append(d.holidays, holidays...)
+	}
+}
+
+type holidaysResponse struct {
+	R struct {
+		Tables []struct {
+			ID       string `json:"id"`
+			DataRows []struct {
+				Name     string `json:"name"`
+				DateFrom string `json:"datefrom"`
+				DateTo   string `json:"dateto"`
+			} `json:"data_rows"`
+		} `json:"tables"`
+	} `json:"r"`
+}
+
+type holidayList struct {
+	holidays []Holiday
+	// err is the failure of the last download, when there is no earlier list to fall back to
+	err error
+	// expiresAt is when the list is downloaded again; failures are retried sooner than successful lists
+	expiresAt time.Time
+}
+
+// holidaysRetryDelay is how long a failed holiday download is remembered, so that every request does not wait for an
+// edupage that is down.
+const holidaysRetryDelay = time.Minute
+
+// Calendar returns non-teaching days between from and to: holidays published in edupage for the school years of the
+// period, plus configured ones. Edupage holidays are skipped with a log message when they can't be downloaded.
+func (d *Downloader) Calendar(ctx context.Context, from time.Time, to time.Time) (*Calendar, error) {
+	loc, err := schoolLocation()
+	if err != nil {
+		return nil, err
+	}
+	result := &Calendar{
+		From:     from.In(loc).Format(time.DateOnly),
+		To:       to.In(loc).Format(time.DateOnly),
+		Holidays: []Holiday{},
+	}
+
+	firstYear, err := schoolYearOf(from)
+	if err != nil {
+		return nil, err
+	}
+	lastYear, err := schoolYearOf(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var holidays []Holiday
+	for year := firstYear; year <= lastYear; year++ {
+		published, err := d.listHolidays(ctx, year)
+		if err != nil {
+			log.Printf("failed to download holidays of %d: %v", year, err)
+			continue
+		}
+		holidays = append(holidays, published...)
+	}
+	holidays = append(holidays, d.holidays...)
+
+	for _, h := range holidays {
+		if h.overlaps(result.From, result.To) {
+			result.Holidays = append(result.Holidays, h)
+		}
+	}
+	slices.SortStableFunc(result.Holidays, func(a, b Holiday) int {
+		return strings.Compare(a.From, b.From)
+	})
+	return result, nil
+}
+
+// SchoolYearCalendar returns non-teaching days of the school year starting in given year.
+func (d *Downloader) SchoolYearCalendar(ctx context.Context, year int) (*Calendar, error) {
+	loc, err := schoolLocation()
+	if err != nil {
+		return nil, err
+	}
+	from := time.Date(year, time.September, 1, 0, 0, 0, 0, loc)
+	to := time.Date(year+1, time.August, 31, 0, 0, 0, 0, loc)
+	return d.Calendar(ctx, from, to)
+}
+
+func (d *Downloader) listHolidays(ctx context.Context, year int) ([]Holiday, error) {
+	d.mu.Lock()
+	list, ok := d.publishedHolidays[year]
+	d.mu.Unlock()
+	if ok && time.Now().Before(list.expiresAt) {
+		return list.holidays, list.err
+	}
+
+	holidays, err := d.shared(ctx, fmt.Sprintf("holidays-%d", year), func(ctx context.Context) (any, error) {
+		return d.downloadHolidays(ctx, year)
+	})
+	if err != nil && ctx.Err() != nil {
+		// the caller gave up, that says nothing about edupage
+		return nil, err
+	}
+	if err != nil {
+		failed := &holidayList{err: err, expiresAt: time.Now().Add(holidaysRetryDelay)}
+		if ok && list.err == nil {
+			failed = &holidayList{holidays: list.holidays, expiresAt: failed.expiresAt}
+		}
+		d.mu.Lock()
+		d.publishedHolidays[year] = failed
+		d.mu.Unlock()
+		return failed.holidays, failed.err
+	}
+	return holidays.([]Holiday), nil
+}
+
+func (d *Downloader) downloadHolidays(ctx context.Context, year int) ([]Holiday, error) {
+	var resp holidaysResponse
+	args := []any{nil, year, map[string]any{}, map[string]any{
+		"op": "fetch",
+		"needed_part": map[string]any{
+			"holidays": []string{"name", "datefrom", "dateto"},
+		},
+	}}
+	if err := d.call(ctx, holidaysLocation, args, &resp); err != nil {
+		return nil, err
+	}
+
+	var result []Holiday
+	for _, table := range resp.R.Tables {
+		if table.ID != "holidays" {
+			continue
+		}
+		for _, row := range table.DataRows {
+			h := Holiday{Name: row.Name, From: row.DateFrom, To: row.DateTo, Source: "edupage"}
+			if h.To == "" {
+				h.To = h.From
+			}
+			if err := h.validate(); err != nil {
+				return nil, err
+			}
+			result = append(result, h)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.publishedHolidays[year] = &holidayList{holidays: result, expiresAt: time.Now().Add(timetablesMaxAge)}
+	return result, nil
+}
+
+// SkipHolidays leaves out class dates that fall on non-teaching days of the calendar.
+func SkipHolidays(c *Calendar) ClassDatesOption {
+	return func(o *classDatesOptions) {
+		o.calendar = c
+	}
+}
@@ -0,0 +1,159 @@
+package schedule
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/fakeedupage"
+)
+
+func TestParseHolidayList(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected []Holiday
+		err      bool
+	}{
+		"empty": {},
+		"days and ranges": {
+			value: "2024-10-28..2024-11-03, 2025-02-17",
+			expected: []Holiday{
+				{From: "2024-10-28", To: "2024-11-03", Source: "config"},
+				{From: "2025-02-17", To: "2025-02-17", Source: "config"},
+			},
+		},
+		"invalid date":     {value: "2024-13-01", err: true},
+		"reversed range":   {value: "2024-11-03..2024-10-28", err: true},
+		"incomplete range": {value: "2024-10-28..", err: true},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			got, err := ParseHolidayList(tt.value)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	r := require.New(t)
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"SUMMARY:Rudens atostogos",
+		"DTSTART;VALUE=DATE:20241028",
+		"DTEND;VALUE=DATE:20241104",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Vasario 16-oji\\, Valstybės atkūrimo",
+		"  diena",
+		"DTSTART;VALUE=DATE:20250217",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Mokytojų konferencija",
+		"DTSTART;TZID=Europe/Vilnius:20250310T000000",
+		"DTEND;TZID=Europe/Vilnius:20250311T000000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Pamokos netrumpinamos",
+		"DTSTART:20250312T060000Z",
+		"DTEND:20250312T100000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	holidays, err := ParseICS(strings.NewReader(ics))
+	r.NoError(err)
+	r.Equal([]Holiday{
+		{Name: "Rudens atostogos", From: "2024-10-28", To: "2024-11-03", Source: "config"},
+		{Name: "Vasario 16-oji, Valstybės atkūrimo diena", From: "2025-02-17", To: "2025-02-17", Source: "config"},
+		{Name: "Mokytojų konferencija", From: "2025-03-10", To: "2025-03-10", Source: "config"},
+		{Name: "Pamokos netrumpinamos", From: "2025-03-12", To: "2025-03-12", Source: "config"},
+	}, holidays)
+
+	_, err = ParseICS(strings.NewReader("BEGIN:VEVENT\nSUMMARY:Be datos\nEND:VEVENT\n"))
+	r.ErrorContains(err, "no DTSTART")
+}
+
+func TestDownloader_Calendar(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+	edupage.SetHolidays(2024, []fakeedupage.Holiday{
+		{Name: "Rudens atostogos", DateFrom: "2024-10-28", DateTo: "2024-11-03"},
+		{Name: "Žiemos atostogos", DateFrom: "2024-12-23", DateTo: "2025-01-03"},
+	})
+
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", "")
+	configured, err := ParseHolidayList("2025-01-06")
+	r.NoError(err)
+	d, err := NewDownloader(WithBaseURL(edupage.URL), WithHolidays(configured))
+	r.NoError(err)
+
+	calendar, err := d.Calendar(ctx, vilniusTime(2024, time.December, 16, 0, 0), vilniusTime(2025, time.January, 12, 0, 0))
+	r.NoError(err)
+	r.Equal(&Calendar{
+		From: "2024-12-16",
+		To:   "2025-01-12",
+		Holidays: []Holiday{
+			{Name: "Žiemos atostogos", From: "2024-12-23", To: "2025-01-03", Source: "edupage"},
+			{From: "2025-01-06", To: "2025-01-06", Source: "config"},
+		},
+	}, calendar)
+	r.True(calendar.IsHoliday(vilniusTime(2025, time.January, 3, 23, 59)))
+	r.False(calendar.IsHoliday(vilniusTime(2025, time.January, 4, 0, 0)))
+
+	yearCalendar, err := d.SchoolYearCalendar(ctx, 2024)
+	r.NoError(err)
+	r.Len(yearCalendar.Holidays, 3)
+	r.Equal(1, edupage.Requests("mainDBIAccessor"), "holidays should be downloaded once per school year")
+
+	s, err := d.GetScheduleAt(ctx, vilniusTime(2024, time.December, 16, 0, 0))
+	r.NoError(err)
+	dates, err := GetClassDates("5d", s, vilniusTime(2024, time.December, 16, 0, 0), vilniusTime(2025, time.January, 12, 0, 0), SkipHolidays(calendar))
+	r.NoError(err)
+	for _, classDate := range dates {
+		for _, date := range classDate.Dates {
+			r.False(calendar.IsHoliday(date), "%s on %s", classDate.Name, date)
+		}
+		if classDate.Name == "Matematika" {
+			r.Len(classDate.Dates, 4, "a week before the break and a week after it")
+		}
+	}
+}
+
+func TestDownloader_Calendar_failure(t *testing.T) {
+	r := require.New(t)
+	var calls atomic.Int32
+	edupage := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		http.Error(writer, "down", http.StatusServiceUnavailable)
+	}))
+	defer edupage.Close()
+
+	configured, err := ParseHolidayList("2025-01-06")
+	r.NoError(err)
+	d, err := NewDownloader(WithBaseURL(edupage.URL), WithCache(nil), WithHolidays(configured))
+	r.NoError(err)
+
+	for range 2 {
+		calendar, err := d.Calendar(context.Background(), vilniusTime(2024, time.December, 16, 0, 0), vilniusTime(2025, time.January, 12, 0, 0))
+		r.NoError(err)
+		r.Equal([]Holiday{{From: "2025-01-06", To: "2025-01-06", Source: "config"}}, calendar.Holidays)
+	}
+	r.Equal(int32(1), calls.Load(), "failed download should not be retried by every request")
+}
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnknownDays is returned for a days mask that does not fit in a week.
+var ErrUnknownDays = errors.New("unknown days mask")
+
+// ErrUnknownWeeks is returned for a weeks mask that has no week in it.
+var ErrUnknownWeeks = errors.New("unknown weeks mask")
+
+// parseDaysMask returns weekdays of a card days mask: one character per day starting with Monday, e.g. "00100" for
+// Wednesday or "100001" for Monday and Saturday in a school with Saturday lessons.
+func parseDaysMask(mask string) ([]time.Weekday, error) {
+	if !isMask(mask) || len(mask) > 7 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownDays, mask)
+	}
+	var result []time.Weekday
+	for i, c := range mask {
+		if c == '1' {
+			result = append(result, time.Weekday((i+1)%7))
+		}
+	}
+	return result, nil
+}
+
+// weekCycle tells which weeks of a repeating cycle a card is taught in. Weeks mask has one character per week of the
+// cycle, e.g. "10" for A weeks and "01" for B weeks of an A/B schedule; "1" is every week.
+type weekCycle string
+
+func parseWeeksMask(mask string) (weekCycle, error) {
+	if mask == "" {
+		return "1", nil
+	}
+	if !isMask(mask) || !strings.Contains(mask, "1") {
+		return "", fmt.Errorf("%w %q", ErrUnknownWeeks, mask)
+	}
+	return weekCycle(mask), nil
+}
+
+// includes reports whether given week of the cycle, zero based and counted with cycleWeek, is taught in.
+func (w weekCycle) includes(week int) bool {
+	return w[week%len(w)] == '1'
+}
+
+// cycleStart returns the day week cycles of the timetable are counted from. Edupage lists the cycle in weeksdefs of a
+// timetable version, starting with the first week the version is valid, so a school shifting the cycle (e.g. after
+// holidays) publishes a new version. Without weeksdefs or a known version the cycle is assumed to restart every school
+// year, with the week of September 1st being the first one.
+func cycleStart(s *Schedule, date time.Time) time.Time {
+	if s.Timetable != nil && len(s.Data().Weeks) > 0 {
+		return s.Timetable.From
+	}
+	return schoolYearStart(date)
+}
+
+// schoolYearStart returns September 1st of the school year date belongs to.
+func schoolYearStart(date time.Time) time.Time {
+	year := date.Year()
+	if date.Month() < time.September {
+		year--
+	}
+	return time.Date(year, time.September, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// cycleWeek returns zero based number of the week containing date, counted from the first week with lessons on or
+// after start: the week of start, or the next one if it falls on a weekend.
+func cycleWeek(start time.Time, date time.Time) int {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	for start.Weekday() == time.Saturday || start.Weekday() == time.Sunday {
+		start = start.AddDate(0, 0, 1)
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Sub(monday(start)).Hours() / 24)
+	if days < 0 {
+		// days before the first lesson week belong to it
+		return 0
+	}
+	return days / 7
+}
+
+func monday(t time.Time) time.Time {
+	return t.AddDate(0, 0, -int((t.Weekday()+6)%7))
+}
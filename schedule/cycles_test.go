@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDaysMask(t *testing.T) {
+	tests := map[string]struct {
+		mask     string
+		expected []time.Weekday
+		err      bool
+	}{
+		"single day":       {mask: "00100", expected: []time.Weekday{time.Wednesday}},
+		"several days":     {mask: "10001", expected: []time.Weekday{time.Monday, time.Friday}},
+		"saturday":         {mask: "000001", expected: []time.Weekday{time.Saturday}},
+		"whole week":       {mask: "0000001", expected: []time.Weekday{time.Sunday}},
+		"not placed":       {mask: "00000"},
+		"longer than week": {mask: "00000001", err: true},
+		"not a mask":       {mask: "monday", err: true},
+		"empty":            {mask: "", err: true},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			got, err := parseDaysMask(tt.mask)
+			if tt.err {
+				require.ErrorIs(t, err, ErrUnknownDays)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestWeekCycle(t *testing.T) {
+	tests := map[string]struct {
+		mask     string
+		date     time.Time
+		expected bool
+	}{
+		"every week":                       {mask: "1", date: vilniusTime(2025, time.January, 8, 8, 0), expected: true},
+		"no mask":                          {mask: "", date: vilniusTime(2025, time.January, 8, 8, 0), expected: true},
+		"first week of school year":        {mask: "10", date: vilniusTime(2025, time.September, 5, 8, 0), expected: true},
+		"second week of school year":       {mask: "10", date: vilniusTime(2025, time.September, 8, 8, 0), expected: false},
+		"B week":                           {mask: "01", date: vilniusTime(2025, time.September, 12, 8, 0), expected: true},
+		"school year starting on a sunday": {mask: "10", date: vilniusTime(2024, time.September, 2, 8, 0), expected: true},
+		"weekend before first week":        {mask: "10", date: vilniusTime(2024, time.September, 1, 8, 0), expected: true},
+		"after new year":                   {mask: "10", date: vilniusTime(2025, time.January, 13, 8, 0), expected: false},
+		"three week cycle":                 {mask: "001", date: vilniusTime(2025, time.September, 15, 8, 0), expected: true},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cycle, err := parseWeeksMask(tt.mask)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, cycle.includes(cycleWeek(schoolYearStart(tt.date), tt.date)))
+		})
+	}
+
+	_, err := parseWeeksMask("00")
+	require.ErrorIs(t, err, ErrUnknownWeeks)
+}
+
+func TestCycleStart(t *testing.T) {
+	// version valid from a Wednesday, after winter holidays
+	version := &TimetableVersion{Num: "2", From: vilniusTime(2025, time.January, 15, 0, 0), To: vilniusTime(2025, time.September, 1, 0, 0)}
+	tests := map[string]struct {
+		rows      map[string]string
+		timetable *TimetableVersion
+		date      time.Time
+		expected  int
+	}{
+		"first week of the version":  {timetable: version, date: vilniusTime(2025, time.January, 13, 8, 0), expected: 0},
+		"second week of the version": {timetable: version, date: vilniusTime(2025, time.January, 21, 8, 0), expected: 1},
+		"without weeks definitions": {
+			rows:      map[string]string{"weeksdefs": "-"},
+			timetable: version,
+			date:      vilniusTime(2025, time.January, 13, 8, 0),
+			expected:  19,
+		},
+		"without known version": {date: vilniusTime(2025, time.January, 13, 8, 0), expected: 19},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			var s Schedule
+			r.NoError(json.Unmarshal([]byte(scheduleJSON(tt.rows)), &s))
+			s.Timetable = tt.timetable
+			r.Equal(tt.expected, cycleWeek(cycleStart(&s, tt.date), tt.date))
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+)
+
+// defaultDisciplineNames map edupage subject names to diary disciplines that can't be matched by name.
+var defaultDisciplineNames = map[string]string{
+	"Tikyba":      "Dorinis ugdymas (tikyba)",
+	"1UK(An)":     "Užsienio kalba (pirmoji, anglų)",
+	"Klasės val.": "Vadovavimas klasei",
+	"Lietuvių k.": "Lietuvių kalba ir literatūra",
+}
+
+// fuzzyThreshold is the lowest similarity of names still considered the same discipline.
+const fuzzyThreshold = 0.8
+
+// MatchKind tells how a timetable subject was matched with a diary discipline.
+type MatchKind string
+
+const (
+	// MatchMapped is a match by configured mapping
+	MatchMapped MatchKind = "mapped"
+	// MatchExact is a match by name, ignoring case, spacing and punctuation
+	MatchExact MatchKind = "exact"
+	// MatchFuzzy is a match by abbreviation or a similar name
+	MatchFuzzy MatchKind = "fuzzy"
+	// MatchNone means no discipline matches the subject
+	MatchNone MatchKind = "none"
+)
+
+// DisciplineMatch is a timetable subject paired with a diary discipline.
+type DisciplineMatch struct {
+	Subject string `json:"subject"`
+	Short   string `json:"short,omitempty"`
+	// Discipline is the diary name; empty when unmatched
+	Discipline string    `json:"discipline,omitempty"`
+	Kind       MatchKind `json:"kind"`
+}
+
+// DisciplineReport tells how timetable subjects were matched with diary disciplines.
+type DisciplineReport struct {
+	Matched []DisciplineMatch `json:"matched"`
+	Fuzzy   []DisciplineMatch `json:"fuzzy"`
+	// UnmatchedSubjects are timetable subjects without a diary discipline; their lessons get no dates
+	UnmatchedSubjects []string `json:"unmatchedSubjects"`
+	// UnmatchedDisciplines are diary disciplines no subject was matched with
+	UnmatchedDisciplines []string `json:"unmatchedDisciplines"`
+}
+
+// DisciplineNames matches edupage subjects with diary disciplines, which are often named differently.
+type DisciplineNames struct {
+	// mapping is by subject name or short name
+	mapping map[string]string
+}
+
+// NewDisciplineNames creates matcher using given mapping of subject names (or short names) to disciplines on top of
+// the built-in one.
+func NewDisciplineNames(mapping map[string]string) *DisciplineNames {
+	merged := map[string]string{}
+	for name, discipline := range defaultDisciplineNames {
+		merged[name] = discipline
+	}
+	for name, discipline := range mapping {
+		merged[name] = discipline
+	}
+	return &DisciplineNames{mapping: merged}
+}
+
+// ParseDisciplineMapping parses semicolon separated subject=discipline pairs, e.g.
+// "Tikyba=Dorinis ugdymas (tikyba);Fizika=Fizika ir astronomija".
+func ParseDisciplineMapping(value string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		subject, discipline, ok := strings.Cut(item, "=")
+		subject, discipline = strings.TrimSpace(subject), strings.TrimSpace(discipline)
+		if !ok || subject == "" || discipline == "" {
+			return nil, fmt.Errorf("invalid discipline mapping %q, expected subject=discipline", item)
+		}
+		result[subject] = discipline
+	}
+	return result, nil
+}
+
+// LoadDisciplineMapping reads configured discipline names: a list as accepted by ParseDisciplineMapping and a JSON file
+// with an object of subject to discipline names; both optional. File entries take precedence.
+func LoadDisciplineMapping(list string, jsonPath string) (map[string]string, error) {
+	result, err := ParseDisciplineMapping(list)
+	if err != nil {
+		return nil, err
+	}
+	if jsonPath == "" {
+		return result, nil
+	}
+
+	contents, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading discipline mapping: %w", err)
+	}
+	var fromFile map[string]string
+	if err := json.Unmarshal(contents, &fromFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", jsonPath, err)
+	}
+	for subject, discipline := range fromFile {
+		result[subject] = discipline
+	}
+	return result, nil
+}
+
+// Match finds the discipline of a subject among diary disciplines. Without known disciplines only the mapping is used,
+// falling back to the subject name.
+func (n *DisciplineNames) Match(subject Subject, disciplines []string) DisciplineMatch {
+	result := DisciplineMatch{Subject: subject.Name, Short: subject.Short, Kind: MatchNone}
+	if n == nil {
+		n = NewDisciplineNames(nil)
+	}
+
+	for _, name := range []string{subject.Name, subject.Short} {
+		mapped, ok := n.mapping[name]
+		if name == "" || !ok {
+			continue
+		}
+		if len(disciplines) == 0 {
+			result.Discipline, result.Kind = mapped, MatchMapped
+			return result
+		}
+		if d, ok := lo.Find(disciplines, func(d string) bool { return normalizeDiscipline(d) == normalizeDiscipline(mapped) }); ok {
+			result.Discipline, result.Kind = d, MatchMapped
+			return result
+		}
+	}
+	if len(disciplines) == 0 {
+		result.Discipline, result.Kind = subject.Name, MatchExact
+		return result
+	}
+
+	for _, name := range []string{subject.Name, subject.Short} {
+		if name == "" {
+			continue
+		}
+		if d, ok := lo.Find(disciplines, func(d string) bool { return normalizeDiscipline(d) == normalizeDiscipline(name) }); ok {
+			result.Discipline, result.Kind = d, MatchExact
+			return result
+		}
+	}
+
+	best, bestScore, tie := "", 0.0, false
+	for _, d := range disciplines {
+		score := max(similarity(subject.Name, d), similarity(subject.Short, d))
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = d, score, false
+		case score == bestScore && d != best:
+			tie = true
+		}
+	}
+	if bestScore >= fuzzyThreshold && !tie {
+		result.Discipline, result.Kind = best, MatchFuzzy
+	}
+	return result
+}
+
+// Report matches subjects with disciplines and sorts them by how they were matched.
+func (n *DisciplineNames) Report(subjects []Subject, disciplines []string) DisciplineReport {
+	report := DisciplineReport{
+		Matched:              []DisciplineMatch{},
+		Fuzzy:                []DisciplineMatch{},
+		UnmatchedSubjects:    []string{},
+		UnmatchedDisciplines: []string{},
+	}
+	used := map[string]bool{}
+	for _, subject := range subjects {
+		match := n.Match(subject, disciplines)
+		used[match.Discipline] = true
+		switch match.Kind {
+		case MatchMapped, MatchExact:
+			report.Matched = append(report.Matched, match)
+		case MatchFuzzy:
+			report.Fuzzy = append(report.Fuzzy, match)
+		default:
+			report.UnmatchedSubjects = append(report.UnmatchedSubjects, subject.Name)
+		}
+	}
+	for _, d := range disciplines {
+		if !used[d] {
+			report.UnmatchedDisciplines = append(report.UnmatchedDisciplines, d)
+		}
+	}
+	return report
+}
+
+// ClassSubjects lists subjects taught to the class, in timetable order. All subjects are listed for an unknown class.
+func ClassSubjects(s *Schedule, className string) []Subject {
+	data := s.Data()
+	class, ok := FindClass(s, className)
+	if !ok {
+		return data.Subjects
+	}
+	taught := map[string]bool{}
+	for _, l := range data.Lessons {
+		if slices.Contains(l.ClassIDs, class.ID) {
+			taught[l.SubjectID] = true
+		}
+	}
+	return lo.Filter(data.Subjects, func(item Subject, _ int) bool {
+		return taught[item.ID]
+	})
+}
+
+// normalizeDiscipline lowercases name and drops punctuation and extra spacing.
+func normalizeDiscipline(name string) string {
+	return strings.Join(disciplineWords(name), " ")
+}
+
+func disciplineWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// similarity rates how likely subject and discipline names stand for the same thing, from 0 to 1. An abbreviation
+// ("Lietuvių k." for "Lietuvių kalba ir literatūra") rates just above fuzzyThreshold; otherwise names are compared by
+// edit distance.
+func similarity(subject string, discipline string) float64 {
+	subjectWords, disciplineWords := disciplineWords(subject), disciplineWords(discipline)
+	if len(subjectWords) == 0 || len(disciplineWords) == 0 {
+		return 0
+	}
+	if isAbbreviation(subjectWords, disciplineWords) {
+		// prefer abbreviations that leave fewer words out
+		return fuzzyThreshold + 0.1*float64(len(subjectWords))/float64(len(disciplineWords))
+	}
+
+	a, b := []rune(strings.Join(subjectWords, " ")), []rune(strings.Join(disciplineWords, " "))
+	return 1 - float64(levenshtein(a, b))/float64(max(len(a), len(b)))
+}
+
+// isAbbreviation tells whether every subject word is a prefix of a discipline word, in order, starting with the first
+// ones; discipline may have extra words.
+func isAbbreviation(subjectWords []string, disciplineWords []string) bool {
+	if !strings.HasPrefix(disciplineWords[0], subjectWords[0]) {
+		return false
+	}
+	i := 0
+	for _, w := range disciplineWords {
+		if i < len(subjectWords) && strings.HasPrefix(w, subjectWords[i]) {
+			i++
+		}
+	}
+	return i == len(subjectWords)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisciplineNames_Match(t *testing.T) {
+	disciplines := []string{
+		"Matematika",
+		"Lietuvių kalba ir literatūra",
+		"Užsienio kalba (pirmoji, anglų)",
+		"Fizinis ugdymas",
+		"Informacinės technologijos",
+		"Biologija",
+		"Technologijos",
+	}
+
+	tests := map[string]struct {
+		mapping  map[string]string
+		subject  Subject
+		expected DisciplineMatch
+	}{
+		"exact": {
+			subject:  Subject{Name: "Matematika", Short: "Mat"},
+			expected: DisciplineMatch{Subject: "Matematika", Short: "Mat", Discipline: "Matematika", Kind: MatchExact},
+		},
+		"ignoring case and spacing": {
+			subject:  Subject{Name: "fizinis  ugdymas"},
+			expected: DisciplineMatch{Subject: "fizinis  ugdymas", Discipline: "Fizinis ugdymas", Kind: MatchExact},
+		},
+		"built-in mapping": {
+			subject:  Subject{Name: "Lietuvių k.", Short: "Lt"},
+			expected: DisciplineMatch{Subject: "Lietuvių k.", Short: "Lt", Discipline: "Lietuvių kalba ir literatūra", Kind: MatchMapped},
+		},
+		"mapping by short name": {
+			mapping:  map[string]string{"Bio": "Biologija"},
+			subject:  Subject{Name: "Gamta ir žmogus", Short: "Bio"},
+			expected: DisciplineMatch{Subject: "Gamta ir žmogus", Short: "Bio", Discipline: "Biologija", Kind: MatchMapped},
+		},
+		"configured mapping wins": {
+			mapping:  map[string]string{"Matematika": "Biologija"},
+			subject:  Subject{Name: "Matematika"},
+			expected: DisciplineMatch{Subject: "Matematika", Discipline: "Biologija", Kind: MatchMapped},
+		},
+		"mapping to a discipline not in the diary": {
+			mapping:  map[string]string{"Biologija": "Gamtos mokslai"},
+			subject:  Subject{Name: "Biologija"},
+			expected: DisciplineMatch{Subject: "Biologija", Discipline: "Biologija", Kind: MatchExact},
+		},
+		"abbreviation": {
+			subject:  Subject{Name: "Fizinis ugd."},
+			expected: DisciplineMatch{Subject: "Fizinis ugd.", Discipline: "Fizinis ugdymas", Kind: MatchFuzzy},
+		},
+		"abbreviation of the longer name": {
+			subject:  Subject{Name: "Inf. technologijos"},
+			expected: DisciplineMatch{Subject: "Inf. technologijos", Discipline: "Informacinės technologijos", Kind: MatchFuzzy},
+		},
+		"typo": {
+			subject:  Subject{Name: "Matematka"},
+			expected: DisciplineMatch{Subject: "Matematka", Discipline: "Matematika", Kind: MatchFuzzy},
+		},
+		"unmatched": {
+			subject:  Subject{Name: "Vokiečių k.", Short: "Vok"},
+			expected: DisciplineMatch{Subject: "Vokiečių k.", Short: "Vok", Kind: MatchNone},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			require.Equal(t, tt.expected, NewDisciplineNames(tt.mapping).Match(tt.subject, disciplines))
+		})
+	}
+
+	// without diary disciplines to match, mapping is trusted
+	require.Equal(t, "Lietuvių kalba ir literatūra", NewDisciplineNames(nil).Match(Subject{Name: "Lietuvių k."}, nil).Discipline)
+	require.Equal(t, "Matematika", NewDisciplineNames(nil).Match(Subject{Name: "Matematika"}, nil).Discipline)
+}
+
+func TestDisciplineNames_Report(t *testing.T) {
+	s := downloadFixtureSchedule(t)
+	report := NewDisciplineNames(map[string]string{"Vokiečių k.": "Užsienio kalba (antroji, vokiečių)"}).Report(
+		ClassSubjects(s, "5d"),
+		[]string{"Matematika", "Lietuvių kalba ir literatūra", "Užsienio kalba (antroji, vokiečių)", "Prancūzų kalba", "Dailė"},
+	)
+	require.Equal(t, DisciplineReport{
+		Matched: []DisciplineMatch{
+			{Subject: "Matematika", Short: "Mat", Discipline: "Matematika", Kind: MatchExact},
+			{Subject: "Lietuvių k.", Short: "Lt", Discipline: "Lietuvių kalba ir literatūra", Kind: MatchMapped},
+			{Subject: "Vokiečių k.", Short: "Vok", Discipline: "Užsienio kalba (antroji, vokiečių)", Kind: MatchMapped},
+		},
+		Fuzzy:                []DisciplineMatch{{Subject: "Prancūzų k.", Short: "Pr", Discipline: "Prancūzų kalba", Kind: MatchFuzzy}},
+		UnmatchedSubjects:    []string{},
+		UnmatchedDisciplines: []string{"Dailė"},
+	}, report)
+
+	require.Len(t, ClassSubjects(s, "6a"), 2)
+	require.Len(t, ClassSubjects(s, ""), 4, "all subjects should be listed for unknown class")
+}
+
+func TestLoadDisciplineMapping(t *testing.T) {
+	r := require.New(t)
+	path := filepath.Join(t.TempDir(), "disciplines.json")
+	r.NoError(os.WriteFile(path, []byte(`{"Tikyba":"Tikyba","Etika":"Dorinis ugdymas (etika)"}`), 0644))
+
+	mapping, err := LoadDisciplineMapping("Tikyba=Dorinis ugdymas (tikyba); Fizika = Fizika ir astronomija;", path)
+	r.NoError(err)
+	r.Equal(map[string]string{
+		"Tikyba": "Tikyba",
+		"Fizika": "Fizika ir astronomija",
+		"Etika":  "Dorinis ugdymas (etika)",
+	}, mapping)
+
+	_, err = ParseDisciplineMapping("Tikyba")
+	r.Error(err)
+	_, err = ParseDisciplineMapping("=Tikyba")
+	r.Error(err)
+	_, err = LoadDisciplineMapping("", filepath.Join(t.TempDir(), "missing.json"))
+	r.Error(err)
+}
@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// ClassDivision is a division that splits a class into several groups, with groups resolved.
+type ClassDivision struct {
+	ID     string  `json:"id"`
+	Groups []Group `json:"groups"`
+}
+
+// ClassDivisions lists ways the class is split into groups; the whole-class division is left out.
+func ClassDivisions(s *Schedule, className string) []ClassDivision {
+	class, ok := FindClass(s, className)
+	if !ok {
+		return nil
+	}
+
+	data := s.Data()
+	var result []ClassDivision
+	for _, division := range data.Divisions {
+		if division.ClassID != class.ID || len(division.GroupIDs) < 2 {
+			continue
+		}
+		cd := ClassDivision{ID: division.ID}
+		for _, id := range division.GroupIDs {
+			if g, ok := data.Group(id); ok && !g.EntireClass {
+				cd.Groups = append(cd.Groups, g)
+			}
+		}
+		if len(cd.Groups) > 1 {
+			result = append(result, cd)
+		}
+	}
+	return result
+}
+
+// InferGroups guesses which groups of the class the student attends, by counting subjects taught to each group that
+// appear among student's diary disciplines. Divisions where no single group stands out (e.g. both groups learn the
+// same subject) are left out, as if nothing was picked for them. Subjects are matched with disciplines by names.
+func InferGroups(s *Schedule, className string, disciplines []string, names *DisciplineNames) []Group {
+	class, ok := FindClass(s, className)
+	if !ok {
+		return nil
+	}
+	attended := lo.SliceToMap(disciplines, func(item string) (string, bool) {
+		return item, true
+	})
+
+	data := s.Data()
+	subjectsByGroup := map[string]map[string]bool{}
+	for _, lesson := range data.Lessons {
+		if !slices.Contains(lesson.ClassIDs, class.ID) {
+			continue
+		}
+		subject, ok := data.Subject(lesson.SubjectID)
+		if !ok {
+			continue
+		}
+		for _, id := range lesson.GroupIDs {
+			if subjectsByGroup[id] == nil {
+				subjectsByGroup[id] = map[string]bool{}
+			}
+			subjectsByGroup[id][names.Match(subject, disciplines).Discipline] = true
+		}
+	}
+
+	var result []Group
+	for _, division := range ClassDivisions(s, className) {
+		best, bestScore, tie := Group{}, 0, false
+		for _, g := range division.Groups {
+			score := len(lo.Filter(lo.Keys(subjectsByGroup[g.ID]), func(subject string, _ int) bool {
+				return attended[subject]
+			}))
+			switch {
+			case score > bestScore:
+				best, bestScore, tie = g, score, false
+			case score == bestScore:
+				tie = true
+			}
+		}
+		if bestScore > 0 && !tie {
+			result = append(result, best)
+		}
+	}
+	return result
+}
+
+type classDatesOptions struct {
+	groups   []string
+	calendar *Calendar
+}
+
+type ClassDatesOption func(o *classDatesOptions)
+
+// WithGroups limits lessons of a split class to ones taught to given groups, referenced by name as group ids differ
+// between timetable versions. Divisions none of the groups belong to are not limited.
+func WithGroups(names []string) ClassDatesOption {
+	return func(o *classDatesOptions) {
+		o.groups = names
+	}
+}
+
+// groupFilter decides whether a lesson is attended by the student, given their picked groups.
+type groupFilter struct {
+	data    *Data
+	classID string
+	// picked are ids of picked groups
+	picked map[string]bool
+	// pickedDivisions are divisions that have a picked group
+	pickedDivisions map[string]bool
+}
+
+func newGroupFilter(data *Data, class Class, names []string) groupFilter {
+	f := groupFilter{
+		data:            data,
+		classID:         class.ID,
+		picked:          map[string]bool{},
+		pickedDivisions: map[string]bool{},
+	}
+	for _, g := range data.Groups {
+		if g.ClassID == class.ID && !g.EntireClass && slices.Contains(names, g.Name) {
+			f.picked[g.ID] = true
+			f.pickedDivisions[g.DivisionID] = true
+		}
+	}
+	return f
+}
+
+// attends reports whether the lesson is taught to the whole class, to a picked group, or to a group of a division
+// nothing was picked for.
+func (f groupFilter) attends(lesson Lesson) bool {
+	classGroups := 0
+	for _, id := range lesson.GroupIDs {
+		g, ok := f.data.Group(id)
+		if !ok || g.ClassID != f.classID {
+			continue
+		}
+		classGroups++
+		if g.EntireClass || f.picked[g.ID] || !f.pickedDivisions[g.DivisionID] {
+			return true
+		}
+	}
+	return classGroups == 0
+}
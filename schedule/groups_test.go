@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassDivisions(t *testing.T) {
+	r := require.New(t)
+	s := downloadFixtureSchedule(t)
+
+	divisions := ClassDivisions(s, "5d")
+	r.Len(divisions, 1)
+	r.Equal([]string{"1 grupė", "2 grupė"}, lo.Map(divisions[0].Groups, func(item Group, _ int) string {
+		return item.Name
+	}))
+
+	r.Empty(ClassDivisions(s, "6a"), "class that is never split has no divisions")
+	r.Empty(ClassDivisions(s, "9z"))
+}
+
+func TestInferGroups(t *testing.T) {
+	s := downloadFixtureSchedule(t)
+
+	tests := map[string]struct {
+		className   string
+		disciplines []string
+		expected    []string
+	}{
+		"subject of one group": {
+			className:   "5d",
+			disciplines: []string{"Matematika", "Lietuvių kalba ir literatūra", "Vokiečių k."},
+			expected:    []string{"1 grupė"},
+		},
+		"subject of other group": {
+			className:   "5d",
+			disciplines: []string{"Prancūzų k."},
+			expected:    []string{"2 grupė"},
+		},
+		"no group subjects": {
+			className:   "5d",
+			disciplines: []string{"Matematika"},
+		},
+		"subjects of both groups": {
+			className:   "5d",
+			disciplines: []string{"Vokiečių k.", "Prancūzų k."},
+		},
+		"class without divisions": {
+			className:   "6a",
+			disciplines: []string{"Matematika"},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			got := lo.Map(InferGroups(s, tt.className, tt.disciplines, NewDisciplineNames(nil)), func(item Group, _ int) string {
+				return item.Name
+			})
+			require.ElementsMatch(t, tt.expected, got)
+		})
+	}
+}
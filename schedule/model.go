@@ -0,0 +1,433 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Data is the typed content of a regulartt download. Rows are kept in edupage order; ones that could not be used are
+// left out and listed in Warnings.
+type Data struct {
+	Periods    []Period
+	Classes    []Class
+	Subjects   []Subject
+	Teachers   []Teacher
+	Classrooms []Classroom
+	Groups     []Group
+	Divisions  []Division
+	Lessons    []Lesson
+	Cards      []Card
+	Days       []DayDef
+	Weeks      []WeekDef
+	Warnings   []RowError
+
+	periodByID    map[string]Period
+	classByID     map[string]Class
+	subjectByID   map[string]Subject
+	teacherByID   map[string]Teacher
+	classroomByID map[string]Classroom
+	groupByID     map[string]Group
+	lessonByID    map[string]Lesson
+}
+
+// Period is a numbered slot of the school day.
+type Period struct {
+	ID     string `json:"id"`
+	Period string `json:"period"`
+	Name   string `json:"name"`
+	Short  string `json:"short"`
+	Start  Clock  `json:"starttime"`
+	End    Clock  `json:"endtime"`
+}
+
+type Subject struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Short string `json:"short"`
+}
+
+type Teacher struct {
+	ID        string `json:"id"`
+	Short     string `json:"short"`
+	FirstName string `json:"firstname"`
+	LastName  string `json:"lastname"`
+}
+
+type Classroom struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Short string `json:"short"`
+}
+
+// Group is a part of a class that some lessons are taught to, e.g. a language group; EntireClass marks the group
+// standing for the whole class.
+type Group struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ClassID     string `json:"classid"`
+	EntireClass bool   `json:"entireclass"`
+	DivisionID  string `json:"divisionid"`
+}
+
+// Division is one way of splitting a class into groups, e.g. by foreign language; a student is in exactly one group of
+// each division.
+type Division struct {
+	ID       string   `json:"id"`
+	ClassID  string   `json:"classid"`
+	GroupIDs []string `json:"groupids"`
+}
+
+// Lesson is a subject taught to some classes (or their groups) Count times a week; it's placed in the timetable by
+// cards.
+type Lesson struct {
+	ID              string   `json:"id"`
+	SubjectID       string   `json:"subjectid"`
+	ClassIDs        []string `json:"classids"`
+	TeacherIDs      []string `json:"teacherids"`
+	GroupIDs        []string `json:"groupids"`
+	Count           float64  `json:"count"`
+	DurationPeriods int      `json:"durationperiods"`
+}
+
+// Card places a lesson at a period on days given by Days mask, e.g. "00100" for Wednesday.
+type Card struct {
+	ID           string   `json:"id"`
+	LessonID     string   `json:"lessonid"`
+	Period       string   `json:"period"`
+	Days         string   `json:"days"`
+	Weeks        string   `json:"weeks"`
+	ClassroomIDs []string `json:"classroomids"`
+}
+
+// DayDef names a days mask, e.g. "Pirmadienis" for "10000".
+type DayDef struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Short string   `json:"short"`
+	Vals  []string `json:"vals"`
+}
+
+// WeekDef names a weeks mask, e.g. "A savaitė" for "10".
+type WeekDef struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Short string   `json:"short"`
+	Vals  []string `json:"vals"`
+}
+
+// Clock is a time of day as written in the timetable, e.g. "8:55".
+type Clock struct {
+	Hour   int
+	Minute int
+}
+
+func ParseClock(value string) (Clock, error) {
+	hour, minute, ok := strings.Cut(strings.TrimSpace(value), ":")
+	h, hErr := strconv.Atoi(hour)
+	m, mErr := strconv.Atoi(minute)
+	if !ok || hErr != nil || mErr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return Clock{}, fmt.Errorf("invalid time of day %q", value)
+	}
+	return Clock{Hour: h, Minute: m}, nil
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%d:%02d", c.Hour, c.Minute)
+}
+
+func (c Clock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *Clock) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseClock(value)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// RowError tells which timetable row could not be used and why; such rows are listed in Data.Warnings.
+type RowError struct {
+	Table string
+	// Row is the index in table's data_rows
+	Row int
+	ID  string
+	Err error
+}
+
+func (e *RowError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("%s row %d: %v", e.Table, e.Row, e.Err)
+	}
+	return fmt.Sprintf("%s row %d (id %s): %v", e.Table, e.Row, e.ID, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ErrMissingTable is returned when a table needed to compute class dates is not in the download.
+var ErrMissingTable = errors.New("missing table")
+
+// parseData decodes raw edupage tables and checks that rows reference each other correctly. Rows that don't fit are
+// dropped with a warning, so one broken lesson does not take the whole timetable down; only a missing required table
+// is an error.
+func parseData(tables []Table) (*Data, error) {
+	byID := map[string]Table{}
+	for _, t := range tables {
+		byID[t.ID] = t
+	}
+
+	d := &Data{}
+	var err error
+	if d.Periods, err = decodeRows[Period](d, byID, "periods", true); err != nil {
+		return nil, err
+	}
+	if d.Classes, err = decodeRows[Class](d, byID, "classes", true); err != nil {
+		return nil, err
+	}
+	if d.Subjects, err = decodeRows[Subject](d, byID, "subjects", true); err != nil {
+		return nil, err
+	}
+	if d.Teachers, err = decodeRows[Teacher](d, byID, "teachers", false); err != nil {
+		return nil, err
+	}
+	if d.Classrooms, err = decodeRows[Classroom](d, byID, "classrooms", false); err != nil {
+		return nil, err
+	}
+	if d.Groups, err = decodeRows[Group](d, byID, "groups", false); err != nil {
+		return nil, err
+	}
+	if d.Divisions, err = decodeRows[Division](d, byID, "divisions", false); err != nil {
+		return nil, err
+	}
+	if d.Lessons, err = decodeRows[Lesson](d, byID, "lessons", true); err != nil {
+		return nil, err
+	}
+	if d.Cards, err = decodeRows[Card](d, byID, "cards", true); err != nil {
+		return nil, err
+	}
+	if d.Days, err = decodeRows[DayDef](d, byID, "daysdefs", false); err != nil {
+		return nil, err
+	}
+	if d.Weeks, err = decodeRows[WeekDef](d, byID, "weeksdefs", false); err != nil {
+		return nil, err
+	}
+
+	d.periodByID = indexRows(d.Periods, func(p Period) string { return p.ID })
+	d.classByID = indexRows(d.Classes, func(c Class) string { return c.ID })
+	d.subjectByID = indexRows(d.Subjects, func(s Subject) string { return s.ID })
+	d.teacherByID = indexRows(d.Teachers, func(t Teacher) string { return t.ID })
+	d.classroomByID = indexRows(d.Classrooms, func(c Classroom) string { return c.ID })
+	d.groupByID = indexRows(d.Groups, func(g Group) string { return g.ID })
+
+	d.Lessons = validRows(d, "lessons", d.Lessons, func(l Lesson) string { return l.ID }, d.validateLesson)
+	d.lessonByID = indexRows(d.Lessons, func(l Lesson) string { return l.ID })
+	d.Divisions = validRows(d, "divisions", d.Divisions, func(division Division) string { return division.ID }, d.validateDivision)
+	d.Cards = validRows(d, "cards", d.Cards, func(c Card) string { return c.ID }, d.validateCard)
+	d.Days = validRows(d, "daysdefs", d.Days, func(day DayDef) string { return day.ID }, validateDayDef)
+	d.Weeks = validRows(d, "weeksdefs", d.Weeks, func(week WeekDef) string { return week.ID }, validateWeekDef)
+	return d, nil
+}
+
+// decodeRows decodes data rows of a table, leaving out rows that do not fit.
+func decodeRows[T any](d *Data, tables map[string]Table, id string, required bool) ([]T, error) {
+	t, ok := tables[id]
+	if !ok {
+		if required {
+			return nil, fmt.Errorf("%w %s", ErrMissingTable, id)
+		}
+		return nil, nil
+	}
+
+	result := make([]T, 0, len(t.DataRows))
+	for i, raw := range t.DataRows {
+		var row T
+		if err := json.Unmarshal(raw, &row); err != nil {
+			d.Warnings = append(d.Warnings, RowError{Table: id, Row: i, ID: rawRowID(raw), Err: err})
+			continue
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// rawRowID extracts id of a row that could not be decoded, to name it in the warning.
+func rawRowID(raw json.RawMessage) string {
+	row := struct {
+		ID any `json:"id"`
+	}{}
+	if err := json.Unmarshal(raw, &row); err != nil || row.ID == nil {
+		return ""
+	}
+	return fmt.Sprint(row.ID)
+}
+
+func indexRows[T any](rows []T, id func(T) string) map[string]T {
+	result := make(map[string]T, len(rows))
+	for _, row := range rows {
+		result[id(row)] = row
+	}
+	return result
+}
+
+// validRows keeps rows that pass validate, recording a warning for each other one.
+func validRows[T any](d *Data, table string, rows []T, id func(T) string, validate func(T) error) []T {
+	// rows that failed to decode are not in rows, count them back to name data_rows index
+	var undecoded []int
+	for _, w := range d.Warnings {
+		if w.Table == table {
+			undecoded = append(undecoded, w.Row)
+		}
+	}
+
+	result := make([]T, 0, len(rows))
+	index := 0
+	for _, row := range rows {
+		for slices.Contains(undecoded, index) {
+			index++
+		}
+		if err := validate(row); err != nil {
+			d.Warnings = append(d.Warnings, RowError{Table: table, Row: index, ID: id(row), Err: err})
+		} else {
+			result = append(result, row)
+		}
+		index++
+	}
+	return result
+}
+
+func (d *Data) validateLesson(l Lesson) error {
+	if _, ok := d.subjectByID[l.SubjectID]; !ok {
+		return fmt.Errorf("unknown subject %q", l.SubjectID)
+	}
+	for _, id := range l.ClassIDs {
+		if _, ok := d.classByID[id]; !ok {
+			return fmt.Errorf("unknown class %q", id)
+		}
+	}
+	for _, id := range l.GroupIDs {
+		if _, ok := d.groupByID[id]; !ok {
+			return fmt.Errorf("unknown group %q", id)
+		}
+	}
+	return nil
+}
+
+func (d *Data) validateDivision(division Division) error {
+	for _, id := range division.GroupIDs {
+		if _, ok := d.groupByID[id]; !ok {
+			return fmt.Errorf("unknown group %q", id)
+		}
+	}
+	return nil
+}
+
+// validateCard checks a card against lessons that passed validation, so cards of dropped lessons are dropped too.
+func (d *Data) validateCard(c Card) error {
+	if _, ok := d.lessonByID[c.LessonID]; !ok {
+		return fmt.Errorf("unknown lesson %q", c.LessonID)
+	}
+	if _, ok := d.periodByID[c.Period]; !ok {
+		return fmt.Errorf("unknown period %q", c.Period)
+	}
+	if _, err := parseDaysMask(c.Days); err != nil {
+		return err
+	}
+	if _, err := parseWeeksMask(c.Weeks); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateDayDef(day DayDef) error {
+	for _, mask := range day.Vals {
+		if _, err := parseDaysMask(mask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateWeekDef(week WeekDef) error {
+	for _, mask := range week.Vals {
+		if _, err := parseWeeksMask(mask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMask reports whether value is a non-empty string of 0 and 1, as used for days and weeks.
+func isMask(value string) bool {
+	return value != "" && strings.Trim(value, "01") == ""
+}
+
+func (d *Data) Period(id string) (Period, bool) {
+	p, ok := d.periodByID[id]
+	return p, ok
+}
+
+func (d *Data) Class(id string) (Class, bool) {
+	c, ok := d.classByID[id]
+	return c, ok
+}
+
+func (d *Data) Subject(id string) (Subject, bool) {
+	s, ok := d.subjectByID[id]
+	return s, ok
+}
+
+func (d *Data) Teacher(id string) (Teacher, bool) {
+	t, ok := d.teacherByID[id]
+	return t, ok
+}
+
+func (d *Data) Classroom(id string) (Classroom, bool) {
+	c, ok := d.classroomByID[id]
+	return c, ok
+}
+
+func (d *Data) Group(id string) (Group, bool) {
+	g, ok := d.groupByID[id]
+	return g, ok
+}
+
+func (d *Data) Lesson(id string) (Lesson, bool) {
+	l, ok := d.lessonByID[id]
+	return l, ok
+}
+
+// teacherName returns full name of the teacher, falling back to the short one or the ID.
+func teacherName(data *Data, id string) string {
+	t, ok := data.Teacher(id)
+	if !ok {
+		return id
+	}
+	if name := strings.TrimSpace(t.FirstName + " " + t.LastName); name != "" {
+		return name
+	}
+	return t.Short
+}
+
+// classroomName returns the short name of the classroom, as shown on its door.
+func classroomName(data *Data, id string) string {
+	c, ok := data.Classroom(id)
+	if !ok {
+		return id
+	}
+	if c.Short != "" {
+		return c.Short
+	}
+	return c.Name
+}
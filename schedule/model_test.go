@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduleJSON builds a minimal regulartt response; rows replace default contents of given tables.
+func scheduleJSON(rows map[string]string) string {
+	tables := map[string]string{
+		"periods":   `{"id": "1", "period": "1", "starttime": "8:00", "endtime": "8:45"}`,
+		"classes":   `{"id": "-10", "name": "5d", "short": "5d"}`,
+		"subjects":  `{"id": "-100", "name": "Matematika", "short": "Mat"}`,
+		"lessons":   `{"id": "-200", "subjectid": "-100", "classids": ["-10"], "count": 1, "durationperiods": 1}`,
+		"cards":     `{"id": "-300", "lessonid": "-200", "period": "1", "days": "10000", "weeks": "1"}`,
+		"daysdefs":  `{"id": "1", "name": "Pirmadienis", "short": "Pr", "vals": ["10000"]}`,
+		"weeksdefs": `{"id": "0", "name": "Kiekvieną savaitę", "short": "", "vals": ["1"]}, {"id": "1", "name": "A savaitė", "short": "A", "vals": ["10"]}`,
+	}
+	for id, r := range rows {
+		tables[id] = r
+	}
+
+	var result []string
+	for id, r := range tables {
+		if r == "-" {
+			continue
+		}
+		result = append(result, `{"id": "`+id+`", "def": {"name": "`+id+`"}, "data_rows": [`+r+`]}`)
+	}
+	return `{"r": {"dbiAccessorRes": {"tables": [` + strings.Join(result, ",") + `]}}}`
+}
+
+func TestSchedule_UnmarshalJSON(t *testing.T) {
+	tests := map[string]struct {
+		rows             map[string]string
+		expectedError    string
+		expectedWarnings []string
+		// expectedCards is the number of cards left, 1 unless some were dropped
+		expectedCards *int
+	}{
+		"valid": {},
+		"wrong field type": {
+			rows:             map[string]string{"cards": `{"id": "-300", "lessonid": "-200", "period": "1", "days": 10000}`},
+			expectedWarnings: []string{"cards row 0 (id -300)"},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"row without id": {
+			rows:             map[string]string{"subjects": `{"id": "-100", "name": "Matematika"}, {"name": 5}`},
+			expectedWarnings: []string{"subjects row 1:"},
+		},
+		"unknown subject": {
+			rows:             map[string]string{"lessons": `{"id": "-200", "subjectid": "-999", "classids": ["-10"]}`},
+			expectedWarnings: []string{`lessons row 0 (id -200): unknown subject "-999"`, `cards row 0 (id -300): unknown lesson "-200"`},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"unknown lesson": {
+			rows:             map[string]string{"cards": `{"id": "-300", "lessonid": "-999", "period": "1", "days": "10000"}`},
+			expectedWarnings: []string{`cards row 0 (id -300): unknown lesson "-999"`},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"unknown period": {
+			rows:             map[string]string{"cards": `{"id": "-300", "lessonid": "-200", "period": "9", "days": "10000"}`},
+			expectedWarnings: []string{`cards row 0 (id -300): unknown period "9"`},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"invalid days": {
+			rows:             map[string]string{"cards": `{"id": "-300", "lessonid": "-200", "period": "1", "days": "monday"}`},
+			expectedWarnings: []string{`cards row 0 (id -300): unknown days mask "monday"`},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"invalid weeks": {
+			rows:             map[string]string{"cards": `{"id": "-300", "lessonid": "-200", "period": "1", "days": "10000", "weeks": "00"}`},
+			expectedWarnings: []string{`cards row 0 (id -300): unknown weeks mask "00"`},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"invalid rows among valid ones": {
+			rows: map[string]string{"cards": `{"id": "-301", "lessonid": "-200", "period": 1}, ` +
+				`{"id": "-302", "lessonid": "-200", "period": "9", "days": "10000"}, ` +
+				`{"id": "-300", "lessonid": "-200", "period": "1", "days": "10000", "weeks": "1"}`},
+			expectedWarnings: []string{"cards row 0 (id -301)", `cards row 1 (id -302): unknown period "9"`},
+		},
+		"invalid period time": {
+			rows:             map[string]string{"periods": `{"id": "1", "period": "1", "starttime": "8h", "endtime": "8:45"}`},
+			expectedWarnings: []string{`periods row 0 (id 1): invalid time of day "8h"`, `cards row 0 (id -300): unknown period "1"`},
+			expectedCards:    lo.ToPtr(0),
+		},
+		"invalid days definition": {
+			rows:             map[string]string{"daysdefs": `{"id": "1", "name": "Pirmadienis", "vals": ["pirmadienis"]}`},
+			expectedWarnings: []string{`daysdefs row 0 (id 1): unknown days mask "pirmadienis"`},
+		},
+		"invalid weeks definition": {
+			rows:             map[string]string{"weeksdefs": `{"id": "0", "name": "Kiekvieną savaitę", "vals": ["1"]}, {"id": "1", "name": "A savaitė", "vals": ["00"]}`},
+			expectedWarnings: []string{`weeksdefs row 1 (id 1): unknown weeks mask "00"`},
+		},
+		"weeks definition of wrong type": {
+			rows:             map[string]string{"weeksdefs": `{"id": "1", "name": "A savaitė", "vals": "10"}`},
+			expectedWarnings: []string{"weeksdefs row 0 (id 1)"},
+		},
+		"without day and week definitions": {
+			rows: map[string]string{"daysdefs": "-", "weeksdefs": "-"},
+		},
+		"missing table": {
+			rows:          map[string]string{"cards": "-"},
+			expectedError: "missing table cards",
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			var s Schedule
+			err := json.Unmarshal([]byte(scheduleJSON(tt.rows)), &s)
+			if tt.expectedError != "" {
+				r.ErrorContains(err, tt.expectedError)
+				return
+			}
+			r.NoError(err)
+
+			data := s.Data()
+			r.Len(data.Warnings, len(tt.expectedWarnings))
+			for i, expected := range tt.expectedWarnings {
+				r.ErrorContains(&data.Warnings[i], expected)
+			}
+			if tt.expectedCards != nil {
+				r.Len(data.Cards, *tt.expectedCards)
+				return
+			}
+
+			r.Len(data.Cards, 1)
+			r.Equal("-300", data.Cards[0].ID)
+			if len(tt.rows) == 0 {
+				r.Equal([]WeekDef{
+					{ID: "0", Name: "Kiekvieną savaitę", Vals: []string{"1"}},
+					{ID: "1", Name: "A savaitė", Short: "A", Vals: []string{"10"}},
+				}, data.Weeks)
+				r.Equal("Pirmadienis", data.Days[0].Name)
+			}
+			lesson, ok := data.Lesson(data.Cards[0].LessonID)
+			r.True(ok)
+			subject, ok := data.Subject(lesson.SubjectID)
+			r.True(ok)
+			r.Equal("Matematika", subject.Name)
+			period, ok := data.Period(data.Cards[0].Period)
+			r.True(ok)
+			r.Equal(Clock{Hour: 8, Minute: 45}, period.End)
+		})
+	}
+}
+
+func TestSchedule_cacheRoundTrip(t *testing.T) {
+	r := require.New(t)
+	var s Schedule
+	r.NoError(json.Unmarshal([]byte(scheduleJSON(nil)), &s))
+
+	contents, err := json.Marshal(&s)
+	r.NoError(err)
+	var restored Schedule
+	r.NoError(json.Unmarshal(contents, &restored))
+	r.Equal(s.Data().Cards, restored.Data().Cards)
+}
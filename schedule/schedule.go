@@ -3,27 +3,33 @@ package schedule
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
 	"net"
 	"net/http"
-	"os"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/samber/lo"
+	"golang.org/x/sync/singleflight"
 )
 
-type DataRow map[string]any
-
+// Table is an edupage table as downloaded; rows are decoded into Data.
 type Table struct {
 	ID  string `json:"id"`
 	Def struct {
 		Name string `json:"name"`
 	} `json:"def"`
-	DataRows []DataRow `json:"data_rows"`
+	DataRows []json.RawMessage `json:"data_rows"`
 }
 
 type Schedule struct {
@@ -32,24 +38,144 @@ type Schedule struct {
 			Tables []Table `json:"tables"`
 		} `json:"DbiAccessorRes"`
 	} `json:"r"`
+	// Timetable is the version this schedule was downloaded for
+	Timetable *TimetableVersion `json:"timetable,omitempty"`
+	// FetchedAt is when the schedule was last downloaded or confirmed unchanged
+	FetchedAt time.Time `json:"fetchedAt"`
+	// Hash identifies timetable content, to tell whether a new download differs from the cached one
+	Hash string `json:"hash,omitempty"`
+
+	data *Data
+}
+
+// UnmarshalJSON decodes and validates the tables, so that a schedule that can't be used is rejected on download
+// instead of failing later.
+func (s *Schedule) UnmarshalJSON(b []byte) error {
+	type raw Schedule
+	var r raw
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	data, err := parseData(r.R.DbiAccessorRes.Tables)
+	if err != nil {
+		return err
+	}
+	*s = Schedule(r)
+	s.data = data
+	return nil
+}
+
+// Data returns typed timetable content; it's empty for a schedule that was not decoded from JSON.
+func (s *Schedule) Data() *Data {
+	if s.data == nil {
+		return &Data{}
+	}
+	return s.data
+}
+
+// Class is a school class as listed in the timetable.
+type Class struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Short string `json:"short"`
 }
 
 type ClassDate struct {
 	Name  string
 	Dates []time.Time
+	// Occurrences describe Dates in detail, one per date and in the same order
+	Occurrences []Occurrence
+	// Changes are substitutions affecting Dates, see ApplyChanges
+	Changes []Change
+}
+
+// Occurrence is a single projected lesson.
+type Occurrence struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Period is the number of the first period of the lesson, e.g. "3"
+	Period  string `json:"period"`
+	Room    string `json:"room,omitempty"`
+	Teacher string `json:"teacher,omitempty"`
 }
 
-// schedule is public, no authentication needed
-const scheduleLocation = "https://vjg.edupage.org/timetable/server/regulartt.js?__func=regularttGetData"
+// DefaultBaseURL is the address of school's edupage; schedule is public, no authentication needed
+const DefaultBaseURL = "https://vjg.edupage.org"
+
+const scheduleLocation = "/timetable/server/regulartt.js?__func=regularttGetData"
 
 type Downloader struct {
-	mu       sync.Mutex
-	Schedule *Schedule
+	// mu guards timetable and holiday lists; schedules have their own lock. Neither is held during downloads, so that a
+	// slow edupage does not block requests that can be served from memory
+	mu sync.Mutex
+	// schedulesMu guards schedules
+	schedulesMu sync.Mutex
+	// schedules are downloaded timetables by their number
+	schedules map[string]*Schedule
+	// flights let concurrent requests share a single download, see shared
+	flights singleflight.Group
+	// scheduleTTL is how long a downloaded schedule is served without checking edupage for changes
+	scheduleTTL time.Duration
+	// timetables are published timetable versions by school year
+	timetables map[int]*timetableList
+	// publishedHolidays are holidays listed in edupage by school year
+	publishedHolidays map[int]*holidayList
+	// holidays are configured in addition to published ones
+	holidays []Holiday
 	client   *http.Client
-	cache    *Cache
+	cache    Cache
+	// cacheSet tells cache was given by WithCache rather than configured by environment
+	cacheSet bool
+	baseURL  string
+}
+
+type timetableList struct {
+	versions  []TimetableVersion
+	fetchedAt time.Time
 }
 
-func NewDownloader() (*Downloader, error) {
+// timetablesMaxAge is how long a list of published timetable versions is trusted before asking edupage again, so that
+// a long-running server notices a new version.
+const timetablesMaxAge = 6 * time.Hour
+
+// sharedTimeout limits downloads shared by concurrent requests. They are detached from the requests that started them,
+// so that one caller giving up does not fail all the others.
+const sharedTimeout = 60 * time.Second
+
+// DefaultScheduleTTL is how long a downloaded schedule is used before it is downloaded again in the background.
+const DefaultScheduleTTL = 24 * time.Hour
+
+type Option func(d *Downloader)
+
+// WithBaseURL points downloader to a different edupage location, e.g. a local stand-in for tests.
+func WithBaseURL(baseURL string) Option {
+	return func(d *Downloader) {
+		if baseURL != "" {
+			d.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithScheduleTTL changes how long a downloaded schedule is used before checking edupage for changes. Stale schedule is
+// still served while a fresh one is being downloaded.
+func WithScheduleTTL(ttl time.Duration) Option {
+	return func(d *Downloader) {
+		if ttl > 0 {
+			d.scheduleTTL = ttl
+		}
+	}
+}
+
+// WithCache sets the cache for downloaded schedules, instead of the one configured by environment variables (see
+// CacheConfigFromEnv). Nil disables caching.
+func WithCache(cache Cache) Option {
+	return func(d *Downloader) {
+		d.cache = cache
+		d.cacheSet = true
+	}
+}
+
+func NewDownloader(opts ...Option) (*Downloader, error) {
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout: 40 * time.Second,
@@ -63,12 +189,23 @@ func NewDownloader() (*Downloader, error) {
 	}
 
 	d := &Downloader{
-		client: c,
+		schedules:         map[string]*Schedule{},
+		timetables:        map[int]*timetableList{},
+		publishedHolidays: map[int]*holidayList{},
+		scheduleTTL:       DefaultScheduleTTL,
+		client:            c,
+		baseURL:           DefaultBaseURL,
+	}
+	for _, o := range opts {
+		o(d)
 	}
 
-	cacheBucket := os.Getenv("CACHE_BUCKET")
-	if cacheBucket != "" {
-		cache, err := NewCache(cacheBucket)
+	if !d.cacheSet {
+		config := CacheConfigFromEnv()
+		if config.Namespace == "" {
+			config.Namespace = schoolNamespace(d.baseURL)
+		}
+		cache, err := NewCache(config)
 		if err != nil {
 			return nil, fmt.Errorf("creating cache: %w", err)
 		}
@@ -78,170 +215,516 @@ func NewDownloader() (*Downloader, error) {
 	return d, nil
 }
 
+// GetSchedule returns schedule of the timetable version valid now.
 func (d *Downloader) GetSchedule(ctx context.Context) (*Schedule, error) {
+	return d.GetScheduleAt(ctx, time.Now())
+}
+
+// GetScheduleAt returns schedule of the timetable version valid at given time.
+func (d *Downloader) GetScheduleAt(ctx context.Context, t time.Time) (*Schedule, error) {
+	year, err := schoolYearOf(t)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := d.Timetables(ctx, year)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := pickTimetable(versions, t)
+	if !ok {
+		return nil, fmt.Errorf("no timetable published for %s", t.Format(time.DateOnly))
+	}
+	return d.getVersion(ctx, version)
+}
+
+// GetSchedules returns schedules of all timetable versions valid during [from, to] range, ordered by time. Each
+// schedule's Timetable tells which part of the range it applies to.
+func (d *Downloader) GetSchedules(ctx context.Context, from time.Time, to time.Time) ([]*Schedule, error) {
+	firstYear, err := schoolYearOf(from)
+	if err != nil {
+		return nil, err
+	}
+	lastYear, err := schoolYearOf(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Schedule
+	for year := firstYear; year <= lastYear; year++ {
+		versions, err := d.Timetables(ctx, year)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range overlappingTimetables(versions, from, to) {
+			s, err := d.getVersion(ctx, version)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, s)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no timetable published for %s - %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	return result, nil
+}
+
+// Timetables returns timetable versions published for the school year starting in given year.
+func (d *Downloader) Timetables(ctx context.Context, year int) ([]TimetableVersion, error) {
+	return d.listTimetables(ctx, year)
+}
+
+// listTimetables returns versions of given school year. Until the school publishes the first timetable of a new year,
+// the last version of the previous year is assumed to continue.
+func (d *Downloader) listTimetables(ctx context.Context, year int) ([]TimetableVersion, error) {
+	versions, err := d.listYearTimetables(ctx, year)
+	if err != nil || len(versions) > 0 {
+		return versions, err
+	}
+
+	previous, err := d.listYearTimetables(ctx, year-1)
+	if err != nil || len(previous) == 0 {
+		return nil, err
+	}
+	loc, err := schoolLocation()
+	if err != nil {
+		return nil, err
+	}
+	continued := previous[len(previous)-1]
+	continued.From = time.Date(year, time.September, 1, 0, 0, 0, 0, loc)
+	continued.To = time.Date(year+1, time.September, 1, 0, 0, 0, 0, loc)
+	return []TimetableVersion{continued}, nil
+}
+
+func (d *Downloader) listYearTimetables(ctx context.Context, year int) ([]TimetableVersion, error) {
+	d.mu.Lock()
+	list, ok := d.timetables[year]
+	d.mu.Unlock()
+	if ok && time.Since(list.fetchedAt) < timetablesMaxAge {
+		return list.versions, nil
+	}
+
+	versions, err := d.shared(ctx, fmt.Sprintf("timetables-%d", year), func(ctx context.Context) (any, error) {
+		return d.downloadTimetables(ctx, year)
+	})
+	if err != nil {
+		if ok {
+			log.Printf("failed to refresh timetable list, using previous one: %v", err)
+			return list.versions, nil
+		}
+		return nil, fmt.Errorf("listing timetables: %w", err)
+	}
+	return versions.([]TimetableVersion), nil
+}
+
+func (d *Downloader) downloadTimetables(ctx context.Context, year int) ([]TimetableVersion, error) {
+	var resp timetablesResponse
+	if err := d.call(ctx, timetablesLocation, []any{nil, year}, &resp); err != nil {
+		return nil, err
+	}
+	versions, err := parseTimetableVersions(resp, year)
+	if err != nil {
+		return nil, err
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	d.timetables[year] = &timetableList{versions: versions, fetchedAt: time.Now()}
+	return versions, nil
+}
+
+// shared runs fn once for concurrent callers with the same key. fn gets a context detached from the callers', with
+// its own timeout; each caller stops waiting once its own ctx is done.
+func (d *Downloader) shared(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	detached := context.WithoutCancel(ctx)
+	result := d.flights.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(detached, sharedTimeout)
+		defer cancel()
+		return fn(ctx)
+	})
+	select {
+	case r := <-result:
+		return r.Val, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-	if d.Schedule == nil {
-		err := d.restoreCache(ctx)
+// getVersion returns schedule of given timetable version. A schedule that is neither loaded nor cached is downloaded
+// right away; a stale one is served as is while a fresh copy is downloaded in the background.
+func (d *Downloader) getVersion(ctx context.Context, version TimetableVersion) (*Schedule, error) {
+	s := d.loadedSchedule(version.Num)
+	if s == nil {
+		loaded, err := d.shared(ctx, "load-"+version.Num, func(ctx context.Context) (any, error) {
+			return d.loadSchedule(ctx, version)
+		})
 		if err != nil {
-			return nil, fmt.Errorf("restoring cache: %w", err)
+			return nil, err
 		}
+		s = loaded.(*Schedule)
+	}
+	if time.Since(s.FetchedAt) >= d.scheduleTTL {
+		d.refreshInBackground(ctx, version)
 	}
 
-	if d.Schedule == nil {
-		println("downloading schedule")
-		s, err := d.downloadSchedule()
+	// bounds of a version change when a newer one is published, so they are taken from the current list rather than
+	// from the time of download
+	result := *s
+	result.Timetable = &version
+	return &result, nil
+}
+
+func (d *Downloader) loadedSchedule(num string) *Schedule {
+	d.schedulesMu.Lock()
+	defer d.schedulesMu.Unlock()
+	return d.schedules[num]
+}
+
+func (d *Downloader) storeSchedule(s *Schedule) {
+	d.schedulesMu.Lock()
+	defer d.schedulesMu.Unlock()
+	d.schedules[s.Timetable.Num] = s
+}
+
+// loadSchedule restores schedule from the cache, downloading it if it's not there.
+func (d *Downloader) loadSchedule(ctx context.Context, version TimetableVersion) (*Schedule, error) {
+	s, err := d.restoreCache(ctx, version.Num)
+	if err != nil {
+		return nil, fmt.Errorf("restoring cache: %w", err)
+	}
+	if s == nil || s.Timetable == nil {
+		return d.refreshSchedule(ctx, version)
+	}
+	d.storeSchedule(s)
+	return s, nil
+}
+
+// refreshInBackground downloads schedule again without making the caller wait; concurrent refreshes of the same
+// schedule are merged into one. Failures are logged, stale schedule stays in use until the next attempt.
+func (d *Downloader) refreshInBackground(ctx context.Context, version TimetableVersion) {
+	detached := context.WithoutCancel(ctx)
+	d.flights.DoChan("refresh-"+version.Num, func() (any, error) {
+		ctx, cancel := context.WithTimeout(detached, sharedTimeout)
+		defer cancel()
+		s, err := d.refreshSchedule(ctx, version)
 		if err != nil {
-			fmt.Printf("failed to download: %v\n", err)
-			return nil, err
+			log.Printf("failed to refresh schedule %s: %v", version.Num, err)
 		}
-		println("download complete")
-		d.Schedule = s
+		return s, err
+	})
+}
+
+// refreshSchedule downloads schedule and stores it. Cache is only rewritten when timetable content has changed;
+// otherwise just the download time is updated, so that a restarted server does not download it again right away.
+func (d *Downloader) refreshSchedule(ctx context.Context, version TimetableVersion) (*Schedule, error) {
+	log.Printf("downloading schedule %s", version.Num)
+	s, err := d.downloadSchedule(ctx, version.Num)
+	if err != nil {
+		return nil, err
+	}
+	s.Timetable = &version
+	s.FetchedAt = time.Now()
 
-		if err := d.updateCache(ctx); err != nil {
+	if previous := d.loadedSchedule(version.Num); previous != nil && previous.Hash == s.Hash {
+		unchanged := *previous
+		unchanged.FetchedAt = s.FetchedAt
+		if err := d.updateCacheMeta(ctx, &unchanged); err != nil {
 			return nil, err
 		}
+		d.storeSchedule(&unchanged)
+		return &unchanged, nil
 	}
 
-	return d.Schedule, nil
+	if err := d.updateCache(ctx, s); err != nil {
+		return nil, err
+	}
+	d.storeSchedule(s)
+	return s, nil
 }
 
-func (d *Downloader) downloadSchedule() (*Schedule, error) {
+func (d *Downloader) downloadSchedule(ctx context.Context, num string) (*Schedule, error) {
+	s := Schedule{}
+	if err := d.call(ctx, scheduleLocation, []any{nil, num}, &s); err != nil {
+		return nil, fmt.Errorf("downloading schedule: %w", err)
+	}
+	hash, err := contentHash(&s)
+	if err != nil {
+		return nil, err
+	}
+	s.Hash = hash
+	if warnings := s.Data().Warnings; len(warnings) > 0 {
+		log.Printf("schedule %s: left out %d rows: %v", num, len(warnings), errors.Join(lo.Map(warnings, func(item RowError, _ int) error {
+			return &item
+		})...))
+	}
+	return &s, nil
+}
 
-	req, err := http.NewRequest("POST", scheduleLocation, bytes.NewBufferString(`{"__args":[null,"48"],"__gsh":"00000000"}`))
+// contentHash digests timetable tables, leaving out download metadata.
+func contentHash(s *Schedule) (string, error) {
+	contents, err := json.Marshal(s.R)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return "", fmt.Errorf("hashing schedule: %w", err)
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// call invokes an edupage function and decodes its JSON response into result.
+func (d *Downloader) call(ctx context.Context, location string, args []any, result any) error {
+	body, err := json.Marshal(map[string]any{
+		"__args": args,
+		"__gsh":  "00000000",
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.baseURL+location, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0"+uuid.New().String())
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("downloading schedule: %w", err)
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
 	}
-	s := Schedule{}
 
-	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
-		return nil, fmt.Errorf("reading json: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("reading json: %w", err)
 	}
-	return &s, nil
+	return nil
+}
+
+func cacheName(num string) string {
+	return "schedule-" + num + ".json"
+}
+
+func cacheMetaName(num string) string {
+	return "schedule-" + num + ".meta.json"
+}
+
+// scheduleMeta is kept next to a cached schedule, to record a download that did not change it.
+type scheduleMeta struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Hash      string    `json:"hash"`
 }
 
-func (d *Downloader) updateCache(ctx context.Context) error {
+func (d *Downloader) updateCache(ctx context.Context, s *Schedule) error {
 	if d.cache == nil {
 		return nil
 	}
 
-	contents, err := json.Marshal(d.Schedule)
+	contents, err := json.Marshal(s)
 	if err != nil {
 		return err
 	}
 
-	return d.cache.Write(ctx, "schedule.json", contents)
+	return d.cache.Write(ctx, cacheName(s.Timetable.Num), contents)
 }
 
-func (d *Downloader) restoreCache(ctx context.Context) error {
+func (d *Downloader) updateCacheMeta(ctx context.Context, s *Schedule) error {
 	if d.cache == nil {
 		return nil
 	}
-	contents, err := d.cache.Read(ctx, "schedule.json")
+
+	contents, err := json.Marshal(scheduleMeta{FetchedAt: s.FetchedAt, Hash: s.Hash})
 	if err != nil {
-		return fmt.Errorf("reading contents: %w", err)
+		return err
+	}
+
+	return d.cache.Write(ctx, cacheMetaName(s.Timetable.Num), contents)
+}
+
+func (d *Downloader) restoreCache(ctx context.Context, num string) (*Schedule, error) {
+	if d.cache == nil {
+		return nil, nil
+	}
+	contents, err := d.cache.Read(ctx, cacheName(num))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading contents: %w", err)
 	}
 	var schedule Schedule
 
 	if err := json.Unmarshal(contents, &schedule); err != nil {
-		return fmt.Errorf("unmarshalling cache: %w", err)
+		return nil, fmt.Errorf("unmarshalling cache: %w", err)
 	}
 
-	d.Schedule = &schedule
-	return nil
+	// metadata is only an optimization, a missing or broken one just means the schedule is refreshed sooner
+	var meta scheduleMeta
+	if contents, err := d.cache.Read(ctx, cacheMetaName(num)); err == nil && json.Unmarshal(contents, &meta) == nil {
+		if meta.Hash == schedule.Hash && meta.FetchedAt.After(schedule.FetchedAt) {
+			schedule.FetchedAt = meta.FetchedAt
+		}
+	}
+
+	return &schedule, nil
 }
 
-func GetClassDates(classID string, s *Schedule, timeFrom time.Time, timeTo time.Time) ([]ClassDate, error) {
-	tableByID := lo.KeyBy(s.R.DbiAccessorRes.Tables, func(item Table) string {
-		return item.ID
-	})
+// ErrClassNotFound is returned when the class is not in the timetable.
+var ErrClassNotFound = errors.New("class not found")
 
-	vilniusLocation, err := time.LoadLocation("Europe/Vilnius")
+func GetClassDates(classID string, s *Schedule, timeFrom time.Time, timeTo time.Time, opts ...ClassDatesOption) ([]ClassDate, error) {
+	options := classDatesOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	vilniusLocation, err := schoolLocation()
 	if err != nil {
-		return nil, fmt.Errorf("loading time zone: %w", err)
+		return nil, err
 	}
 
-	targetClassData, found := lo.Find(tableByID["classes"].DataRows, func(item DataRow) bool {
-		return item["short"] == classID
-	})
+	targetClass, found := FindClass(s, classID)
 	if !found {
-		return nil, fmt.Errorf("class %s not found", classID)
+		return nil, fmt.Errorf("%w: %s", ErrClassNotFound, classID)
 	}
 
-	externalClassID := targetClassData["id"].(string)
-
-	lessons := lo.Filter(tableByID["lessons"].DataRows, func(item DataRow, index int) bool {
-		for _, id := range item["classids"].([]any) {
-			if id == externalClassID {
-				return true
-			}
-		}
-		return false
+	data := s.Data()
+	groups := newGroupFilter(data, targetClass, options.groups)
+	lessons := lo.Filter(data.Lessons, func(item Lesson, index int) bool {
+		return slices.Contains(item.ClassIDs, targetClass.ID) && groups.attends(item)
 	})
-	subjects := rowsByID(tableByID["subjects"])
-	cards := rowsByID(tableByID["cards"])
-	periods := rowsByID(tableByID["periods"])
+	cardsByLesson := lo.GroupBy(data.Cards, func(item Card) string {
+		return item.LessonID
+	})
+	day := timeFrom.In(vilniusLocation)
 	var result []ClassDate
-	for _, row := range lessons {
-		subj := subjects[row["subjectid"].(string)]
+	for _, lesson := range lessons {
+		subj, ok := data.Subject(lesson.SubjectID)
+		if !ok {
+			return nil, fmt.Errorf("lesson %s: unknown subject %s", lesson.ID, lesson.SubjectID)
+		}
+		teacher := strings.Join(lo.Map(lesson.TeacherIDs, func(id string, _ int) string {
+			return teacherName(data, id)
+		}), ", ")
 		dates := ClassDate{
-			Name: subj["name"].(string),
+			Name: subj.Name,
 		}
-		for _, card := range cards {
-			if card["lessonid"].(string) != row["id"].(string) {
-				continue
+		for _, card := range cardsByLesson[lesson.ID] {
+			period, ok := data.Period(card.Period)
+			if !ok {
+				return nil, fmt.Errorf("card %s: unknown period %s", card.ID, card.Period)
 			}
-			period := periods[card["period"].(string)]
+			end := lessonEnd(data, period, lesson.DurationPeriods)
+			room := strings.Join(lo.Map(card.ClassroomIDs, func(id string, _ int) string {
+				return classroomName(data, id)
+			}), ", ")
 
-			t := time.Now().Truncate(time.Minute)
-			t, err := time.ParseInLocation("2006-01-02 15:04", t.Format("2006-01-02")+" "+period["starttime"].(string), vilniusLocation)
+			weekdays, err := parseDaysMask(card.Days)
 			if err != nil {
-				return nil, fmt.Errorf("parsing time: %w", err)
+				return nil, fmt.Errorf("card %s: %w", card.ID, err)
+			}
+			weeks, err := parseWeeksMask(card.Weeks)
+			if err != nil {
+				return nil, fmt.Errorf("card %s: %w", card.ID, err)
 			}
 
-			classDate := getClassDateByWeekday(t, card["days"].(string))
-
-			dates.Dates = append(dates.Dates, extrapolateClassDates(classDate, timeFrom, timeTo)...)
+			t := time.Date(day.Year(), day.Month(), day.Day(), period.Start.Hour, period.Start.Minute, 0, 0, vilniusLocation)
+			for _, weekday := range weekdays {
+				classDate := getClassDateByWeekday(t, weekday)
+				for _, d := range extrapolateClassDates(classDate, timeFrom, timeTo) {
+					if !weeks.includes(cycleWeek(cycleStart(s, d), d)) || options.calendar.IsHoliday(d) {
+						continue
+					}
+					dates.Occurrences = append(dates.Occurrences, Occurrence{
+						Start:   d,
+						End:     time.Date(d.Year(), d.Month(), d.Day(), end.Hour, end.Minute, 0, 0, vilniusLocation),
+						Period:  period.Period,
+						Room:    room,
+						Teacher: teacher,
+					})
+				}
+			}
 		}
-		slices.SortFunc(dates.Dates, func(a, b time.Time) int {
-			return a.Compare(b)
+		slices.SortFunc(dates.Occurrences, func(a, b Occurrence) int {
+			return a.Start.Compare(b.Start)
+		})
+		dates.Dates = lo.Map(dates.Occurrences, func(item Occurrence, _ int) time.Time {
+			return item.Start
 		})
 		result = append(result, dates)
 	}
 	return result, nil
 }
 
-func rowsByID(t Table) map[string]DataRow {
-	return lo.KeyBy(t.DataRows, func(item DataRow) string {
-		return item["id"].(string)
+// lessonEnd returns the time lesson starting at given period ends; lessons may last several consecutive periods.
+func lessonEnd(data *Data, start Period, durationPeriods int) Clock {
+	if durationPeriods <= 1 {
+		return start.End
+	}
+	number, err := strconv.Atoi(start.Period)
+	if err != nil {
+		return start.End
+	}
+	last, ok := lo.Find(data.Periods, func(item Period) bool {
+		return item.Period == strconv.Itoa(number+durationPeriods-1)
 	})
+	if !ok {
+		return start.End
+	}
+	return last.End
 }
 
-func maskToWeekday(mask string) time.Weekday {
-	switch mask {
-	case "10000":
-		return time.Monday
-	case "01000":
-		return time.Tuesday
-	case "00100":
-		return time.Wednesday
-	case "00010":
-		return time.Thursday
-	case "00001":
-		return time.Friday
+// GetClassDatesAcross returns class dates over a range covered by several timetable versions, using each schedule only
+// for the dates its Timetable is valid. Versions that don't have the class are skipped.
+func GetClassDatesAcross(className string, schedules []*Schedule, timeFrom time.Time, timeTo time.Time, opts ...ClassDatesOption) ([]ClassDate, error) {
+	var result []ClassDate
+	found := false
+	for _, s := range schedules {
+		from, to := timeFrom, timeTo
+		if s.Timetable != nil {
+			var ok bool
+			if from, to, ok = s.Timetable.Clamp(from, to); !ok {
+				continue
+			}
+		}
+		if _, ok := FindClass(s, className); !ok {
+			continue
+		}
+		found = true
+
+		dates, err := GetClassDates(className, s, from, to, opts...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, dates...)
 	}
-	panic("unknown weekday " + mask)
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrClassNotFound, className)
+	}
+	return result, nil
 }
 
-func getClassDateByWeekday(t time.Time, mask string) time.Time {
-	result := t.AddDate(0, 0, int((maskToWeekday(mask)-t.Weekday()+7)%7))
+// ListClasses returns all classes in the timetable.
+func ListClasses(s *Schedule) []Class {
+	return s.Data().Classes
+}
+
+// FindClass finds a class by its short name, ignoring case and spacing ("5d", "5 D").
+func FindClass(s *Schedule, short string) (Class, bool) {
+	normalized := normalizeClassName(short)
+	return lo.Find(ListClasses(s), func(item Class) bool {
+		return normalizeClassName(item.Short) == normalized
+	})
+}
+
+func normalizeClassName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+// getClassDateByWeekday returns the first date on given weekday, starting with t.
+func getClassDateByWeekday(t time.Time, weekday time.Weekday) time.Time {
+	result := t.AddDate(0, 0, int((weekday-t.Weekday()+7)%7))
 
 	return result
 }
@@ -262,19 +745,3 @@ func extrapolateClassDates(date time.Time, from time.Time, to time.Time) []time.
 	}
 	return result
 }
-
-var internalNames = map[string]string{
-	"Tikyba":      "Dorinis ugdymas (tikyba)",
-	"1UK(An)":     "Užsienio kalba (pirmoji, anglų)",
-	"Klasės val.": "Vadovavimas klasei",
-	"Lietuvių k.": "Lietuvių kalba ir literatūra",
-}
-
-// ToInternalName returns corresponing discipline name in internal system.
-// Some class names are mismatched between public schedule and internal class system
-func ToInternalName(name string) string {
-	if overide, ok := internalNames[name]; ok {
-		return overide
-	}
-	return name
-}
@@ -2,29 +2,435 @@ package schedule
 
 import (
 	"context"
+	"encoding/json"
+	"io/fs"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/fakeedupage"
 )
 
-func Test_parseSchedule(t *testing.T) {
-	//contents, err := os.ReadFile("rttdata.json")
-	//if err != nil {
-	//	t.Skipf("failed to read sample rttdata.json: %v", err)
-	//}
-	//s := Schedule{}
-	// require.NoError(t, json.Unmarshal(contents, &s))
-	d, err := NewDownloader()
+var vilnius = lo.Must(time.LoadLocation("Europe/Vilnius"))
+
+func vilniusTime(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, vilnius)
+}
+
+func downloadFixtureSchedule(t *testing.T) *Schedule {
+	t.Helper()
+	edupage := fakeedupage.NewServer()
+	t.Cleanup(edupage.Close)
+
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", "")
+	d, err := NewDownloader(WithBaseURL(edupage.URL))
 	require.NoError(t, err)
 	s, err := d.GetSchedule(context.Background())
 	require.NoError(t, err)
+	return s
+}
 
-	weekAhead := time.Now().Add(time.Hour * 24 * 7)
-	monthBack := weekAhead.Add(-time.Hour * 24 * 30)
+func TestDownloader_GetSchedule(t *testing.T) {
+	r := require.New(t)
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
 
-	result, err := GetClassDates("5d", s, monthBack, weekAhead)
-	require.NoError(t, err)
-	spew.Dump(result)
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+	d, err := NewDownloader(WithBaseURL(edupage.URL))
+	r.NoError(err)
+
+	s, err := d.GetSchedule(context.Background())
+	r.NoError(err)
+	r.Len(s.Data().Classes, 2)
+	r.Equal(fakeedupage.DefaultTimetable, s.Timetable.Num)
+
+	_, err = d.GetSchedule(context.Background())
+	r.NoError(err)
+	r.Equal(1, edupage.Requests("regularttGetData"), "schedule should be downloaded once")
+
+	// new downloader picks schedule up from cache
+	d, err = NewDownloader(WithBaseURL(edupage.URL))
+	r.NoError(err)
+	_, err = d.GetSchedule(context.Background())
+	r.NoError(err)
+	r.Equal(1, edupage.Requests("regularttGetData"))
+}
+
+// countingCache is an in-memory cache recording writes by name.
+type countingCache struct {
+	mu     sync.Mutex
+	files  map[string][]byte
+	writes map[string]int
+}
+
+func (c *countingCache) Read(_ context.Context, name string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	contents, ok := c.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return contents, nil
+}
+
+func (c *countingCache) Write(_ context.Context, name string, contents []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[name] = contents
+	c.writes[name]++
+	return nil
+}
+
+func TestDownloader_staleSchedule(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", "")
+	cache := &countingCache{files: map[string][]byte{}, writes: map[string]int{}}
+	d, err := NewDownloader(WithBaseURL(edupage.URL), WithScheduleTTL(time.Hour), WithCache(cache))
+	r.NoError(err)
+
+	s, err := d.GetSchedule(ctx)
+	r.NoError(err)
+	r.NotEmpty(s.Hash)
+	r.WithinDuration(time.Now(), s.FetchedAt, time.Minute)
+	name := cacheName(s.Timetable.Num)
+	r.Equal(1, cache.writes[name])
+
+	// pretend the schedule was downloaded long ago
+	stale := *d.loadedSchedule(s.Timetable.Num)
+	stale.FetchedAt = time.Now().Add(-2 * time.Hour)
+	d.storeSchedule(&stale)
+
+	s, err = d.GetSchedule(ctx)
+	r.NoError(err)
+	r.Equal(stale.FetchedAt, s.FetchedAt, "stale schedule should be served while refreshing")
+	r.Eventually(func() bool {
+		return time.Since(d.loadedSchedule(s.Timetable.Num).FetchedAt) < time.Minute
+	}, 5*time.Second, 10*time.Millisecond)
+	r.Equal(2, edupage.Requests("regularttGetData"))
+	cache.mu.Lock()
+	r.Equal(1, cache.writes[name], "unchanged schedule should not be rewritten")
+	r.Equal(1, cache.writes[cacheMetaName(s.Timetable.Num)], "download time should be recorded")
+	cache.mu.Unlock()
+
+	s, err = d.GetSchedule(ctx)
+	r.NoError(err)
+	r.WithinDuration(time.Now(), s.FetchedAt, time.Minute)
+	r.Equal(2, edupage.Requests("regularttGetData"), "refreshed schedule should be fresh")
+
+	// restarted server knows the unchanged schedule was checked recently
+	d, err = NewDownloader(WithBaseURL(edupage.URL), WithScheduleTTL(time.Hour), WithCache(cache))
+	r.NoError(err)
+	s, err = d.GetSchedule(ctx)
+	r.NoError(err)
+	r.WithinDuration(time.Now(), s.FetchedAt, time.Minute)
+	time.Sleep(50 * time.Millisecond)
+	r.Equal(2, edupage.Requests("regularttGetData"), "recently checked schedule should not be refreshed")
+
+	// a cached copy without download time, e.g. from an older version, is used and refreshed
+	d, err = NewDownloader(WithBaseURL(edupage.URL), WithCache(cache))
+	r.NoError(err)
+	var cached Schedule
+	r.NoError(json.Unmarshal(cache.files[name], &cached))
+	cached.FetchedAt = time.Time{}
+	cached.Hash = ""
+	cache.files[name], err = json.Marshal(cached)
+	r.NoError(err)
+	s, err = d.GetSchedule(ctx)
+	r.NoError(err)
+	r.True(s.FetchedAt.IsZero())
+	r.Eventually(func() bool {
+		return edupage.Requests("regularttGetData") == 3 && !d.loadedSchedule(s.Timetable.Num).FetchedAt.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+	cache.mu.Lock()
+	r.Equal(2, cache.writes[name], "schedule without hash should be rewritten")
+	cache.mu.Unlock()
+}
+
+func TestDownloader_cancelledCaller(t *testing.T) {
+	r := require.New(t)
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+
+	d, err := NewDownloader(WithBaseURL(edupage.URL), WithCache(nil))
+	r.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.GetSchedule(ctx)
+	r.ErrorIs(err, context.Canceled)
+
+	// the shared download is not cancelled together with the caller that started it
+	s, err := d.GetSchedule(context.Background())
+	r.NoError(err)
+	r.Equal(fakeedupage.DefaultTimetable, s.Timetable.Num)
+	r.Equal(1, edupage.Requests("getTTViewerData"))
+}
+
+func TestGetClassDates(t *testing.T) {
+	s := downloadFixtureSchedule(t)
+
+	tests := map[string]struct {
+		classID string
+		groups  []string
+		from    time.Time
+		to      time.Time
+		// versionFrom is when the timetable version starts, September 1st of the school year by default
+		versionFrom time.Time
+		expected    map[string][]time.Time
+	}{
+		"single week": {
+			classID: "5d",
+			from:    vilniusTime(2025, time.January, 6, 0, 0),
+			to:      vilniusTime(2025, time.January, 12, 23, 59),
+			expected: map[string][]time.Time{
+				"Matematika":  {vilniusTime(2025, time.January, 8, 8, 55), vilniusTime(2025, time.January, 10, 8, 0)},
+				"Lietuvių k.": {vilniusTime(2025, time.January, 10, 9, 50)},
+				"Vokiečių k.": {vilniusTime(2025, time.January, 7, 9, 50)},
+				"Prancūzų k.": {vilniusTime(2025, time.January, 7, 9, 50)},
+			},
+		},
+		"picked group": {
+			classID: "5d",
+			groups:  []string{"2 grupė"},
+			from:    vilniusTime(2025, time.January, 6, 0, 0),
+			to:      vilniusTime(2025, time.January, 12, 23, 59),
+			expected: map[string][]time.Time{
+				"Matematika":  {vilniusTime(2025, time.January, 8, 8, 55), vilniusTime(2025, time.January, 10, 8, 0)},
+				"Lietuvių k.": {vilniusTime(2025, time.January, 10, 9, 50)},
+				"Prancūzų k.": {vilniusTime(2025, time.January, 7, 9, 50)},
+			},
+		},
+		"group of another class": {
+			classID: "5d",
+			groups:  []string{"Visa klasė", "3 grupė"},
+			from:    vilniusTime(2025, time.January, 6, 0, 0),
+			to:      vilniusTime(2025, time.January, 12, 23, 59),
+			expected: map[string][]time.Time{
+				"Matematika":  {vilniusTime(2025, time.January, 8, 8, 55), vilniusTime(2025, time.January, 10, 8, 0)},
+				"Lietuvių k.": {vilniusTime(2025, time.January, 10, 9, 50)},
+				"Vokiečių k.": {vilniusTime(2025, time.January, 7, 9, 50)},
+				"Prancūzų k.": {vilniusTime(2025, time.January, 7, 9, 50)},
+			},
+		},
+		"range starting mid week": {
+			classID: "5d",
+			from:    vilniusTime(2025, time.January, 9, 10, 0),
+			to:      vilniusTime(2025, time.January, 17, 8, 0),
+			expected: map[string][]time.Time{
+				"Matematika":  {vilniusTime(2025, time.January, 10, 8, 0), vilniusTime(2025, time.January, 15, 8, 55), vilniusTime(2025, time.January, 17, 8, 0)},
+				"Lietuvių k.": {vilniusTime(2025, time.January, 10, 9, 50)},
+				"Vokiečių k.": {vilniusTime(2025, time.January, 14, 9, 50)},
+				"Prancūzų k.": {vilniusTime(2025, time.January, 14, 9, 50)},
+			},
+		},
+		"daylight saving time starts": {
+			classID: "5d",
+			from:    vilniusTime(2025, time.March, 24, 0, 0),
+			to:      vilniusTime(2025, time.April, 5, 0, 0),
+			expected: map[string][]time.Time{
+				"Matematika": {
+					vilniusTime(2025, time.March, 26, 8, 55), vilniusTime(2025, time.March, 28, 8, 0),
+					vilniusTime(2025, time.April, 2, 8, 55), vilniusTime(2025, time.April, 4, 8, 0),
+				},
+				"Lietuvių k.": {vilniusTime(2025, time.March, 28, 9, 50), vilniusTime(2025, time.April, 4, 9, 50)},
+				"Vokiečių k.": {vilniusTime(2025, time.March, 25, 9, 50), vilniusTime(2025, time.April, 1, 9, 50)},
+				"Prancūzų k.": {vilniusTime(2025, time.March, 25, 9, 50), vilniusTime(2025, time.April, 1, 9, 50)},
+			},
+		},
+		"daylight saving time ends": {
+			classID: "6a",
+			from:    vilniusTime(2024, time.October, 21, 0, 0),
+			to:      vilniusTime(2024, time.November, 4, 12, 0),
+			expected: map[string][]time.Time{
+				"Matematika":  {vilniusTime(2024, time.October, 21, 8, 0), vilniusTime(2024, time.October, 28, 8, 0), vilniusTime(2024, time.November, 4, 8, 0)},
+				"Lietuvių k.": {vilniusTime(2024, time.October, 26, 8, 0), vilniusTime(2024, time.October, 29, 8, 55)},
+			},
+		},
+		"alternating weeks and saturday": {
+			// Tuesday lessons are on A weeks, Saturday ones on B weeks; school year starts with an A week
+			classID: "6a",
+			from:    vilniusTime(2025, time.September, 1, 0, 0),
+			to:      vilniusTime(2025, time.September, 21, 0, 0),
+			expected: map[string][]time.Time{
+				"Matematika": {vilniusTime(2025, time.September, 1, 8, 0), vilniusTime(2025, time.September, 8, 8, 0), vilniusTime(2025, time.September, 15, 8, 0)},
+				"Lietuvių k.": {
+					vilniusTime(2025, time.September, 2, 8, 55), vilniusTime(2025, time.September, 13, 8, 0), vilniusTime(2025, time.September, 16, 8, 55),
+				},
+			},
+		},
+		"cycle restarted by a new version": {
+			// counting from September, January 13th would be a B week
+			classID:     "6a",
+			from:        vilniusTime(2025, time.January, 13, 0, 0),
+			to:          vilniusTime(2025, time.January, 26, 0, 0),
+			versionFrom: vilniusTime(2025, time.January, 13, 0, 0),
+			expected: map[string][]time.Time{
+				"Matematika":  {vilniusTime(2025, time.January, 13, 8, 0), vilniusTime(2025, time.January, 20, 8, 0)},
+				"Lietuvių k.": {vilniusTime(2025, time.January, 14, 8, 55), vilniusTime(2025, time.January, 25, 8, 0)},
+			},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			version := *s.Timetable
+			version.From = tt.versionFrom
+			if version.From.IsZero() {
+				version.From = schoolYearStart(tt.from)
+			}
+			scheduled := *s
+			scheduled.Timetable = &version
+			result, err := GetClassDates(tt.classID, &scheduled, tt.from, tt.to, WithGroups(tt.groups))
+			r.NoError(err)
+
+			got := lo.SliceToMap(result, func(item ClassDate) (string, []time.Time) {
+				return item.Name, item.Dates
+			})
+			r.Equal(len(tt.expected), len(got))
+			for name, expectedDates := range tt.expected {
+				r.Len(got[name], len(expectedDates), name)
+				for i, expected := range expectedDates {
+					r.True(expected.Equal(got[name][i]), "%s: expected %s, got %s", name, expected, got[name][i])
+					r.Equal(expected.Format("15:04"), got[name][i].Format("15:04"), "local lesson time should not shift")
+				}
+			}
+		})
+	}
+
+	_, err := GetClassDates("9z", s, time.Now(), time.Now().AddDate(0, 0, 7))
+	require.Error(t, err)
+}
+
+func TestGetClassDates_occurrences(t *testing.T) {
+	r := require.New(t)
+	s := downloadFixtureSchedule(t)
+
+	result, err := GetClassDates("5d", s, vilniusTime(2025, time.January, 6, 0, 0), vilniusTime(2025, time.January, 12, 23, 59))
+	r.NoError(err)
+	got := lo.SliceToMap(result, func(item ClassDate) (string, []Occurrence) {
+		return item.Name, item.Occurrences
+	})
+	r.Equal([]Occurrence{
+		{Start: vilniusTime(2025, time.January, 8, 8, 55), End: vilniusTime(2025, time.January, 8, 9, 40), Period: "2", Room: "204", Teacher: "Petras Petraitis"},
+		{Start: vilniusTime(2025, time.January, 10, 8, 0), End: vilniusTime(2025, time.January, 10, 8, 45), Period: "1", Room: "204", Teacher: "Petras Petraitis"},
+	}, got["Matematika"])
+	r.Equal([]Occurrence{
+		{Start: vilniusTime(2025, time.January, 7, 9, 50), End: vilniusTime(2025, time.January, 7, 10, 35), Period: "3", Room: "SS", Teacher: "Ona Onaitienė"},
+	}, got["Prancūzų k."])
+	for _, classDate := range result {
+		r.Equal(classDate.Dates, lo.Map(classDate.Occurrences, func(item Occurrence, _ int) time.Time {
+			return item.Start
+		}), classDate.Name)
+	}
+
+	data := s.Data()
+	period, ok := data.Period("1")
+	r.True(ok)
+	r.Equal(Clock{Hour: 8, Minute: 45}, lessonEnd(data, period, 1))
+	r.Equal(Clock{Hour: 9, Minute: 40}, lessonEnd(data, period, 2), "double lesson should end with the second period")
+	r.Equal(Clock{Hour: 8, Minute: 45}, lessonEnd(data, period, 5), "periods past the last one are unknown")
+}
+
+func TestGetClassDateByWeekday(t *testing.T) {
+	tests := map[string]struct {
+		from     time.Time
+		weekday  time.Weekday
+		expected time.Time
+	}{
+		"same day":                {from: vilniusTime(2025, time.January, 8, 8, 55), weekday: time.Wednesday, expected: vilniusTime(2025, time.January, 8, 8, 55)},
+		"later this week":         {from: vilniusTime(2025, time.January, 6, 8, 0), weekday: time.Friday, expected: vilniusTime(2025, time.January, 10, 8, 0)},
+		"next week":               {from: vilniusTime(2025, time.January, 10, 8, 0), weekday: time.Monday, expected: vilniusTime(2025, time.January, 13, 8, 0)},
+		"from sunday":             {from: vilniusTime(2025, time.January, 12, 8, 0), weekday: time.Monday, expected: vilniusTime(2025, time.January, 13, 8, 0)},
+		"from saturday":           {from: vilniusTime(2025, time.January, 11, 8, 0), weekday: time.Tuesday, expected: vilniusTime(2025, time.January, 14, 8, 0)},
+		"saturday":                {from: vilniusTime(2025, time.January, 6, 8, 0), weekday: time.Saturday, expected: vilniusTime(2025, time.January, 11, 8, 0)},
+		"over daylight time jump": {from: vilniusTime(2025, time.March, 29, 8, 0), weekday: time.Monday, expected: vilniusTime(2025, time.March, 31, 8, 0)},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			got := getClassDateByWeekday(tt.from, tt.weekday)
+			require.True(t, tt.expected.Equal(got), "expected %s, got %s", tt.expected, got)
+		})
+	}
+}
+
+func TestExtrapolateClassDates(t *testing.T) {
+	wednesday := vilniusTime(2025, time.March, 19, 8, 55)
+
+	tests := map[string]struct {
+		date     time.Time
+		from     time.Time
+		to       time.Time
+		expected []time.Time
+	}{
+		"date before range": {
+			date:     wednesday,
+			from:     vilniusTime(2025, time.April, 1, 0, 0),
+			to:       vilniusTime(2025, time.April, 10, 0, 0),
+			expected: []time.Time{vilniusTime(2025, time.April, 2, 8, 55), vilniusTime(2025, time.April, 9, 8, 55)},
+		},
+		"date after range": {
+			date:     wednesday,
+			from:     vilniusTime(2025, time.February, 24, 0, 0),
+			to:       vilniusTime(2025, time.March, 6, 0, 0),
+			expected: []time.Time{vilniusTime(2025, time.February, 26, 8, 55), vilniusTime(2025, time.March, 5, 8, 55)},
+		},
+		"range bounds": {
+			// lesson exactly at range start is excluded, one exactly at range end is included
+			date:     wednesday,
+			from:     wednesday,
+			to:       wednesday.AddDate(0, 0, 14),
+			expected: []time.Time{vilniusTime(2025, time.March, 26, 8, 55), vilniusTime(2025, time.April, 2, 8, 55)},
+		},
+		"range shorter than a week": {
+			date: wednesday,
+			from: vilniusTime(2025, time.March, 20, 0, 0),
+			to:   vilniusTime(2025, time.March, 25, 0, 0),
+		},
+		"over daylight time jump": {
+			date:     wednesday,
+			from:     vilniusTime(2025, time.March, 20, 0, 0),
+			to:       vilniusTime(2025, time.April, 3, 0, 0),
+			expected: []time.Time{vilniusTime(2025, time.March, 26, 8, 55), vilniusTime(2025, time.April, 2, 8, 55)},
+		},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			r := require.New(t)
+			got := extrapolateClassDates(tt.date, tt.from, tt.to)
+			r.Len(got, len(tt.expected))
+			for i := range tt.expected {
+				r.True(tt.expected[i].Equal(got[i]), "expected %s, got %s", tt.expected[i], got[i])
+				r.Equal("08:55", got[i].Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestFindClass(t *testing.T) {
+	r := require.New(t)
+	s := downloadFixtureSchedule(t)
+
+	r.Len(ListClasses(s), 2)
+
+	for _, name := range []string{"5d", "5D", " 5 d"} {
+		c, ok := FindClass(s, name)
+		r.True(ok, name)
+		r.Equal(Class{ID: "-10", Name: "5d", Short: "5d"}, c)
+	}
+
+	_, ok := FindClass(s, "5e")
+	r.False(ok)
 }
@@ -0,0 +1,231 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const changesLocation = "/timetable/server/currenttt.js?__func=curentttGetData"
+
+// Change is a difference from the regular timetable of a class on a particular day, as published in edupage
+// substitutions.
+type Change struct {
+	// Subject is the name of lesson's subject, as in ClassDate.Name
+	Subject string    `json:"subject"`
+	Start   time.Time `json:"start"`
+	Period  string    `json:"period"`
+	// Cancelled lessons are left out of ClassDate.Dates
+	Cancelled bool `json:"cancelled,omitempty"`
+	// SubstituteTeacher is set when lesson is taught by a different teacher than usual
+	SubstituteTeacher string `json:"substituteTeacher,omitempty"`
+	// Room is set when lesson takes place in a different room than usual
+	Room string `json:"room,omitempty"`
+}
+
+type changesResponse struct {
+	R struct {
+		Items []changeItem `json:"ttitems"`
+	} `json:"r"`
+}
+
+// changeItem is a lesson of the actual timetable; regular lessons come along with changed and removed ones.
+type changeItem struct {
+	Type         string   `json:"type"`
+	Date         string   `json:"date"`
+	Period       string   `json:"uniperiod"`
+	StartTime    string   `json:"starttime"`
+	SubjectID    string   `json:"subjectid"`
+	ClassIDs     []string `json:"classids"`
+	TeacherIDs   []string `json:"teacherids"`
+	ClassroomIDs []string `json:"classroomids"`
+	Removed      bool     `json:"removed"`
+	Changed      bool     `json:"changed"`
+}
+
+// GetChanges downloads substitutions of the class between from and to, resolved against given schedule. Items that
+// can't be resolved, e.g. of a subject missing in the schedule, are skipped with a log message.
+func (d *Downloader) GetChanges(ctx context.Context, s *Schedule, className string, from time.Time, to time.Time) ([]Change, error) {
+	class, ok := FindClass(s, className)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClassNotFound, className)
+	}
+	loc, err := schoolLocation()
+	if err != nil {
+		return nil, err
+	}
+	year, err := schoolYearOf(from)
+	if err != nil {
+		return nil, err
+	}
+	if s.Timetable != nil {
+		year = s.Timetable.Year
+	}
+
+	var resp changesResponse
+	args := []any{nil, map[string]any{
+		"year":     year,
+		"datefrom": from.In(loc).Format(time.DateOnly),
+		"dateto":   to.In(loc).Format(time.DateOnly),
+		"table":    "classes",
+		"id":       class.ID,
+		"showOrig": true,
+	}}
+	if err := d.call(ctx, changesLocation, args, &resp); err != nil {
+		return nil, fmt.Errorf("downloading substitutions: %w", err)
+	}
+
+	var result []Change
+	var skipped []error
+	for _, item := range resp.R.Items {
+		if item.Type != "card" || !(item.Removed || item.Changed) || !slices.Contains(item.ClassIDs, class.ID) {
+			continue
+		}
+		change, ok, err := resolveChange(s.Data(), class, item, loc)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		if ok {
+			result = append(result, change)
+		}
+	}
+	if len(skipped) > 0 {
+		log.Printf("skipped %d substitutions of %s: %v", len(skipped), className, errors.Join(skipped...))
+	}
+	return result, nil
+}
+
+// resolveChange describes a changed timetable item in terms of the regular lesson it replaces; ok is false when
+// nothing student cares about has changed.
+func resolveChange(data *Data, class Class, item changeItem, loc *time.Location) (Change, bool, error) {
+	subject, ok := data.Subject(item.SubjectID)
+	if !ok {
+		return Change{}, false, fmt.Errorf("substitution on %s: unknown subject %s", item.Date, item.SubjectID)
+	}
+	day, err := time.ParseInLocation(time.DateOnly, item.Date, loc)
+	if err != nil {
+		return Change{}, false, fmt.Errorf("substitution of %s: %w", subject.Name, err)
+	}
+	start, err := ParseClock(item.StartTime)
+	if err != nil {
+		period, ok := data.Period(item.Period)
+		if !ok {
+			return Change{}, false, fmt.Errorf("substitution of %s on %s: %w", subject.Name, item.Date, err)
+		}
+		start = period.Start
+	}
+
+	change := Change{
+		Subject:   subject.Name,
+		Start:     time.Date(day.Year(), day.Month(), day.Day(), start.Hour, start.Minute, 0, 0, loc),
+		Period:    item.Period,
+		Cancelled: item.Removed,
+	}
+	if item.Removed {
+		return change, true, nil
+	}
+
+	teacherIDs, classroomIDs := regularStaffing(data, class, item.SubjectID, day.Weekday(), item.Period)
+	if !sameIDs(teacherIDs, item.TeacherIDs) {
+		change.SubstituteTeacher = strings.Join(lo.Map(item.TeacherIDs, func(id string, _ int) string {
+			return teacherName(data, id)
+		}), ", ")
+	}
+	if !sameIDs(classroomIDs, item.ClassroomIDs) {
+		change.Room = strings.Join(lo.Map(item.ClassroomIDs, func(id string, _ int) string {
+			return classroomName(data, id)
+		}), ", ")
+	}
+	return change, change.SubstituteTeacher != "" || change.Room != "", nil
+}
+
+// regularStaffing finds teachers and rooms of the regular lesson of the subject at given weekday and period.
+func regularStaffing(data *Data, class Class, subjectID string, weekday time.Weekday, period string) ([]string, []string) {
+	for _, card := range data.Cards {
+		if card.Period != period {
+			continue
+		}
+		lesson, ok := data.Lesson(card.LessonID)
+		if !ok || lesson.SubjectID != subjectID || !slices.Contains(lesson.ClassIDs, class.ID) {
+			continue
+		}
+		weekdays, err := parseDaysMask(card.Days)
+		if err != nil || !slices.Contains(weekdays, weekday) {
+			continue
+		}
+		return lesson.TeacherIDs, card.ClassroomIDs
+	}
+	return nil, nil
+}
+
+func sameIDs(a []string, b []string) bool {
+	return len(a) == len(b) && len(lo.Without(a, b...)) == 0
+}
+
+// GetChangesAcross downloads substitutions over a range covered by several timetable versions, like
+// GetClassDatesAcross.
+func (d *Downloader) GetChangesAcross(ctx context.Context, schedules []*Schedule, className string, timeFrom time.Time, timeTo time.Time) ([]Change, error) {
+	var result []Change
+	for _, s := range schedules {
+		from, to := timeFrom, timeTo
+		if s.Timetable != nil {
+			var ok bool
+			if from, to, ok = s.Timetable.Clamp(from, to); !ok {
+				continue
+			}
+		}
+		if _, ok := FindClass(s, className); !ok {
+			continue
+		}
+
+		changes, err := d.GetChanges(ctx, s, className, from, to)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, changes...)
+	}
+	return result, nil
+}
+
+// ApplyChanges records substitutions in class dates they belong to, leaving cancelled lessons out of Dates and
+// updating teachers and rooms of the occurrences.
+func ApplyChanges(dates []ClassDate, changes []Change) []ClassDate {
+	result := slices.Clone(dates)
+	for i := range result {
+		cd := &result[i]
+		for _, change := range changes {
+			if change.Subject != cd.Name || !slices.ContainsFunc(cd.Dates, change.Start.Equal) {
+				continue
+			}
+			cd.Changes = append(cd.Changes, change)
+			if change.Cancelled {
+				cd.Dates = slices.DeleteFunc(slices.Clone(cd.Dates), change.Start.Equal)
+				cd.Occurrences = slices.DeleteFunc(slices.Clone(cd.Occurrences), func(o Occurrence) bool {
+					return change.Start.Equal(o.Start)
+				})
+				continue
+			}
+			cd.Occurrences = slices.Clone(cd.Occurrences)
+			for j := range cd.Occurrences {
+				o := &cd.Occurrences[j]
+				if !change.Start.Equal(o.Start) {
+					continue
+				}
+				if change.SubstituteTeacher != "" {
+					o.Teacher = change.SubstituteTeacher
+				}
+				if change.Room != "" {
+					o.Room = change.Room
+				}
+			}
+		}
+	}
+	return result
+}
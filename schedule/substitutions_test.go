@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/fakeedupage"
+)
+
+func TestDownloader_GetChanges(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+	edupage.SetChanges([]fakeedupage.Change{
+		{Date: "2024-10-09", Period: "2", StartTime: "8:55", SubjectID: "-100", ClassID: "-10", Removed: true},
+		{Date: "2024-10-11", Period: "1", SubjectID: "-100", ClassID: "-10", TeacherIDs: []string{"-600"}, ClassroomIDs: []string{"-702"}},
+		{Date: "2024-10-11", Period: "3", StartTime: "9:50", SubjectID: "-101", ClassID: "-10", TeacherIDs: []string{"-602"}, ClassroomIDs: []string{"-701"}},
+		// moved within the day but taught as usual
+		{Date: "2024-10-08", Period: "3", StartTime: "9:50", SubjectID: "-102", ClassID: "-10", TeacherIDs: []string{"-602"}, ClassroomIDs: []string{"-701"}},
+		{Date: "2024-10-08", Period: "2", StartTime: "8:55", SubjectID: "-101", ClassID: "-11", Removed: true},
+		{Date: "2024-10-16", Period: "2", StartTime: "8:55", SubjectID: "-100", ClassID: "-10", Removed: true},
+		// broken items don't take the others down
+		{Date: "2024-10-10", Period: "2", StartTime: "8:55", SubjectID: "-999", ClassID: "-10", Removed: true},
+		{Date: "2024-10-10", Period: "4", StartTime: "soon", SubjectID: "-100", ClassID: "-10", Removed: true},
+	})
+
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", "")
+	d, err := NewDownloader(WithBaseURL(edupage.URL))
+	r.NoError(err)
+
+	from, to := vilniusTime(2024, time.October, 7, 0, 0), vilniusTime(2024, time.October, 13, 0, 0)
+	schedules, err := d.GetSchedules(ctx, from, to)
+	r.NoError(err)
+	changes, err := d.GetChangesAcross(ctx, schedules, "5d", from, to)
+	r.NoError(err)
+	r.Equal([]Change{
+		{Subject: "Matematika", Start: vilniusTime(2024, time.October, 9, 8, 55), Period: "2", Cancelled: true},
+		{Subject: "Matematika", Start: vilniusTime(2024, time.October, 11, 8, 0), Period: "1", Room: "SS"},
+		{Subject: "Lietuvių k.", Start: vilniusTime(2024, time.October, 11, 9, 50), Period: "3", SubstituteTeacher: "Jurga Jurgaitė"},
+	}, changes)
+
+	dates, err := GetClassDatesAcross("5d", schedules, from, to)
+	r.NoError(err)
+	applied := ApplyChanges(dates, changes)
+	for _, classDate := range applied {
+		switch classDate.Name {
+		case "Matematika":
+			r.Equal([]time.Time{vilniusTime(2024, time.October, 11, 8, 0)}, classDate.Dates)
+			r.Equal(changes[:2], classDate.Changes)
+			r.Len(classDate.Occurrences, 1)
+			r.Equal("SS", classDate.Occurrences[0].Room)
+		case "Lietuvių k.":
+			r.Equal([]time.Time{vilniusTime(2024, time.October, 11, 9, 50)}, classDate.Dates)
+			r.Equal(changes[2:], classDate.Changes)
+			r.Equal("Jurga Jurgaitė", classDate.Occurrences[0].Teacher)
+			r.Equal("105", classDate.Occurrences[0].Room)
+		default:
+			r.Empty(classDate.Changes, classDate.Name)
+		}
+	}
+	r.Len(dates[0].Dates, len(applied[0].Dates)+1, "original dates should be left intact")
+}
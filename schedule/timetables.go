@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const timetablesLocation = "/timetable/server/ttviewer.js?__func=getTTViewerData"
+
+// TimetableVersion is one of the timetables school publishes during a school year. Each version is valid from its
+// start date until the next version starts, the last one - until the end of the school year. The first version also
+// covers days of the school year before it was published.
+type TimetableVersion struct {
+	Num  string `json:"num"`
+	Text string `json:"text"`
+	// Year is the calendar year the school year starts in
+	Year int       `json:"year"`
+	From time.Time `json:"from"`
+	// To is exclusive
+	To time.Time `json:"to"`
+}
+
+// Contains reports whether the version is valid at given time.
+func (v TimetableVersion) Contains(t time.Time) bool {
+	return !t.Before(v.From) && t.Before(v.To)
+}
+
+// Clamp narrows [from, to] range to the validity of the version; ok is false if they don't overlap.
+func (v TimetableVersion) Clamp(from time.Time, to time.Time) (time.Time, time.Time, bool) {
+	if from.Before(v.From) {
+		from = v.From
+	}
+	if !to.Before(v.To) {
+		to = v.To.Add(-time.Nanosecond)
+	}
+	return from, to, !to.Before(from)
+}
+
+type timetablesResponse struct {
+	R struct {
+		Regular struct {
+			Timetables []timetableItem `json:"timetables"`
+		} `json:"regular"`
+	} `json:"r"`
+}
+
+type timetableItem struct {
+	Num      string `json:"tt_num"`
+	Year     int    `json:"year"`
+	Text     string `json:"text"`
+	DateFrom string `json:"datefrom"`
+	Hidden   bool   `json:"hidden"`
+}
+
+// parseTimetableVersions converts edupage timetable list of given school year into date-bounded versions, ordered by
+// start date. Hidden (draft) versions are skipped.
+func parseTimetableVersions(resp timetablesResponse, year int) ([]TimetableVersion, error) {
+	loc, err := schoolLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TimetableVersion
+	for _, item := range resp.R.Regular.Timetables {
+		if item.Hidden {
+			continue
+		}
+		from, err := time.ParseInLocation(time.DateOnly, item.DateFrom, loc)
+		if err != nil {
+			return nil, fmt.Errorf("timetable %s: parsing start date: %w", item.Num, err)
+		}
+		result = append(result, TimetableVersion{
+			Num:  item.Num,
+			Text: item.Text,
+			Year: year,
+			From: from,
+		})
+	}
+	slices.SortStableFunc(result, func(a, b TimetableVersion) int {
+		return a.From.Compare(b.From)
+	})
+
+	yearStart := time.Date(year, time.September, 1, 0, 0, 0, 0, loc)
+	yearEnd := time.Date(year+1, time.September, 1, 0, 0, 0, 0, loc)
+	if len(result) > 0 && result[0].From.After(yearStart) {
+		result[0].From = yearStart
+	}
+	for i := range result {
+		if i+1 < len(result) {
+			result[i].To = result[i+1].From
+		} else {
+			result[i].To = yearEnd
+		}
+	}
+	// a version replaced on its very first day was never in effect
+	return slices.DeleteFunc(result, func(v TimetableVersion) bool {
+		return !v.To.After(v.From)
+	}), nil
+}
+
+// pickTimetable returns version valid at given time.
+func pickTimetable(versions []TimetableVersion, t time.Time) (TimetableVersion, bool) {
+	for _, v := range versions {
+		if v.Contains(t) {
+			return v, true
+		}
+	}
+	return TimetableVersion{}, false
+}
+
+// overlappingTimetables returns versions that are valid at least part of [from, to] range.
+func overlappingTimetables(versions []TimetableVersion, from time.Time, to time.Time) []TimetableVersion {
+	var result []TimetableVersion
+	for _, v := range versions {
+		if _, _, ok := v.Clamp(from, to); ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// schoolYearOf returns the calendar year the school year containing t starts in.
+func schoolYearOf(t time.Time) (int, error) {
+	loc, err := schoolLocation()
+	if err != nil {
+		return 0, err
+	}
+	t = t.In(loc)
+	if t.Month() >= time.September {
+		return t.Year(), nil
+	}
+	return t.Year() - 1, nil
+}
+
+func schoolLocation() (*time.Location, error) {
+	loc, err := time.LoadLocation("Europe/Vilnius")
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone: %w", err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,121 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/fakeedupage"
+)
+
+func TestParseTimetableVersions(t *testing.T) {
+	r := require.New(t)
+	var resp timetablesResponse
+	resp.R.Regular.Timetables = append(resp.R.Regular.Timetables,
+		timetableItem{Num: "48", DateFrom: "2025-01-13"},
+		timetableItem{Num: "49", DateFrom: "2025-02-03", Hidden: true},
+		timetableItem{Num: "47", DateFrom: "2024-09-02"},
+	)
+
+	versions, err := parseTimetableVersions(resp, 2024)
+	r.NoError(err)
+	r.Len(versions, 2)
+
+	r.Equal("47", versions[0].Num)
+	r.True(vilniusTime(2024, time.September, 1, 0, 0).Equal(versions[0].From), "first version covers start of the school year")
+	r.True(vilniusTime(2025, time.January, 13, 0, 0).Equal(versions[0].To))
+	r.Equal("48", versions[1].Num)
+	r.True(vilniusTime(2025, time.January, 13, 0, 0).Equal(versions[1].From))
+	r.True(vilniusTime(2025, time.September, 1, 0, 0).Equal(versions[1].To))
+
+	tests := map[string]struct {
+		at       time.Time
+		expected string
+	}{
+		"first day of school year":  {at: vilniusTime(2024, time.September, 1, 8, 0), expected: "47"},
+		"last day of first version": {at: vilniusTime(2025, time.January, 12, 23, 59), expected: "47"},
+		"first day of next version": {at: vilniusTime(2025, time.January, 13, 0, 0), expected: "48"},
+		"after hidden version date": {at: vilniusTime(2025, time.March, 1, 8, 0), expected: "48"},
+		"next school year":          {at: vilniusTime(2025, time.September, 1, 8, 0)},
+	}
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			v, ok := pickTimetable(versions, tt.at)
+			require.Equal(t, tt.expected != "", ok)
+			require.Equal(t, tt.expected, v.Num)
+		})
+	}
+
+	overlapping := overlappingTimetables(versions, vilniusTime(2025, time.January, 6, 0, 0), vilniusTime(2025, time.January, 19, 0, 0))
+	r.Equal([]string{"47", "48"}, lo.Map(overlapping, func(item TimetableVersion, _ int) string {
+		return item.Num
+	}))
+}
+
+func TestDownloader_TimetableVersions(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	edupage := fakeedupage.NewServer()
+	defer edupage.Close()
+	edupage.SetTimetables(2024, []fakeedupage.Timetable{
+		{Num: "47", DateFrom: "2024-09-02"},
+		{Num: "48", DateFrom: "2025-01-13"},
+	})
+	edupage.SetTimetables(2025, nil)
+
+	t.Setenv("CACHE_BUCKET", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+	d, err := NewDownloader(WithBaseURL(edupage.URL))
+	r.NoError(err)
+
+	s, err := d.GetScheduleAt(ctx, vilniusTime(2025, time.January, 10, 12, 0))
+	r.NoError(err)
+	r.Equal("47", s.Timetable.Num)
+
+	s, err = d.GetScheduleAt(ctx, vilniusTime(2025, time.January, 13, 12, 0))
+	r.NoError(err)
+	r.Equal("48", s.Timetable.Num)
+
+	// nothing published for the new school year yet: last version continues
+	s, err = d.GetScheduleAt(ctx, vilniusTime(2025, time.October, 1, 12, 0))
+	r.NoError(err)
+	r.Equal("48", s.Timetable.Num)
+	r.True(vilniusTime(2025, time.September, 1, 0, 0).Equal(s.Timetable.From))
+
+	r.Equal(2, edupage.Requests("regularttGetData"), "each version should be downloaded once")
+	r.Equal(2, edupage.Requests("getTTViewerData"), "timetable list should be requested once per school year")
+
+	schedules, err := d.GetSchedules(ctx, vilniusTime(2025, time.January, 6, 0, 0), vilniusTime(2025, time.January, 19, 0, 0))
+	r.NoError(err)
+	r.Len(schedules, 2)
+
+	dates, err := GetClassDatesAcross("5d", schedules, vilniusTime(2025, time.January, 6, 0, 0), vilniusTime(2025, time.January, 19, 0, 0), WithGroups([]string{"1 grupė"}))
+	r.NoError(err)
+	got := map[string][]time.Time{}
+	for _, d := range dates {
+		got[d.Name] = append(got[d.Name], d.Dates...)
+	}
+	expected := map[string][]time.Time{
+		// version 47 has mathematics on Monday and Thursday, version 48 - on Wednesday and Friday
+		"Matematika": {
+			vilniusTime(2025, time.January, 6, 8, 55), vilniusTime(2025, time.January, 9, 8, 0),
+			vilniusTime(2025, time.January, 15, 8, 55), vilniusTime(2025, time.January, 17, 8, 0),
+		},
+		"Lietuvių k.": {vilniusTime(2025, time.January, 10, 9, 50), vilniusTime(2025, time.January, 17, 9, 50)},
+		"Vokiečių k.": {vilniusTime(2025, time.January, 7, 9, 50), vilniusTime(2025, time.January, 14, 9, 50)},
+	}
+	r.Len(got, len(expected))
+	for name, expectedDates := range expected {
+		actual := got[name]
+		r.Len(actual, len(expectedDates), name)
+		for _, e := range expectedDates {
+			r.True(lo.ContainsBy(actual, e.Equal), "%s: missing %s in %v", name, e, actual)
+		}
+	}
+
+	_, err = GetClassDatesAcross("9z", schedules, vilniusTime(2025, time.January, 6, 0, 0), vilniusTime(2025, time.January, 19, 0, 0))
+	r.Error(err)
+}
@@ -2,20 +2,24 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	fs2 "io/fs"
+	"log"
 	"net/http"
+	"os"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/gorilla/mux"
 	"github.com/samber/lo"
 
 	"vjgdienynas/collector"
 	"vjgdienynas/schedule"
+	"vjgdienynas/session"
 	"vjgdienynas/ui"
 )
 
@@ -25,24 +29,103 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Name string `json:"name"`
+	Name  string `json:"name"`
+	Class string `json:"class,omitempty"`
+}
+
+type LessonInfoResponse struct {
+	Lessons []*collector.LessonInfo `json:"lessons"`
+	// FailedLessons are listed in Lessons, but without details that could not be fetched from the diary
+	FailedLessons []collector.LessonFailure `json:"failedLessons"`
+}
+
+type ClassRequest struct {
+	// Class is short class name as listed by /api/classes; empty value reverts to class detected in the diary
+	Class string `json:"class"`
+}
+
+type GroupsRequest struct {
+	// Groups are group names as listed by GET /api/groups; empty list reverts to groups inferred from the diary
+	Groups []string `json:"groups"`
+}
+
+type GroupsResponse struct {
+	// Divisions lists group names of each way student's class is split, e.g. by foreign language
+	Divisions [][]string `json:"divisions"`
+	// Groups are student's groups: picked by the user, or else inferred from the diary
+	Groups   []string `json:"groups"`
+	Inferred bool     `json:"inferred"`
+}
+
+type server struct {
+	sessions           *sessionCookies
+	scheduleDownloader *schedule.Downloader
+	// disciplines match timetable subjects with diary disciplines
+	disciplines *schedule.DisciplineNames
+	// markWeights weigh marks by category in /api/stats averages
+	markWeights collector.MarkWeights
+	// diaryURL overrides diary location, e.g. for running against a local stand-in
+	diaryURL string
 }
 
 func BuildServer() (*mux.Router, error) {
+	sessions, err := newSessionCookies()
+	if err != nil {
+		return nil, fmt.Errorf("configuring sessions: %w", err)
+	}
+	holidays, err := schedule.LoadHolidays(os.Getenv("HOLIDAYS"), os.Getenv("HOLIDAYS_ICS"))
+	if err != nil {
+		return nil, fmt.Errorf("loading holidays: %w", err)
+	}
+	var scheduleTTL time.Duration
+	if value := os.Getenv("SCHEDULE_TTL"); value != "" {
+		if scheduleTTL, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("parsing SCHEDULE_TTL: %w", err)
+		}
+	}
+	scheduleDownloader, err := schedule.NewDownloader(
+		schedule.WithBaseURL(os.Getenv("EDUPAGE_URL")),
+		schedule.WithHolidays(holidays),
+		schedule.WithScheduleTTL(scheduleTTL),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating schedule downloader: %w", err)
+	}
+	disciplineMapping, err := schedule.LoadDisciplineMapping(os.Getenv("DISCIPLINES"), os.Getenv("DISCIPLINES_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("loading discipline names: %w", err)
+	}
+	markWeights, err := collector.ParseMarkWeights(os.Getenv("MARK_WEIGHTS"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing MARK_WEIGHTS: %w", err)
+	}
+	s := &server{
+		sessions:           sessions,
+		scheduleDownloader: scheduleDownloader,
+		disciplines:        schedule.NewDisciplineNames(disciplineMapping),
+		markWeights:        markWeights,
+		diaryURL:           os.Getenv("DIARY_URL"),
+	}
+
 	// Create a new ServeMux router
 	mux := mux.NewRouter()
 
 	api := mux.PathPrefix("/api").Subrouter()
 
-	api.HandleFunc("/login", loggedInHandler).Methods("GET")
-	api.HandleFunc("/login", loginHandler).Methods("POST")
-	api.HandleFunc("/logout", logoutHandler).Methods("POST")
-
-	h, err := lessonInfoHandler()
-	if err != nil {
-		return nil, fmt.Errorf("creating lesson info handler: %w", err)
-	}
-	api.HandleFunc("/lesson-info", h).Methods("GET")
+	api.HandleFunc("/login", s.loggedInHandler).Methods("GET")
+	api.HandleFunc("/login", s.loginHandler).Methods("POST")
+	api.HandleFunc("/logout", s.logoutHandler).Methods("POST")
+	api.HandleFunc("/lesson-info", s.lessonInfoHandler).Methods("GET")
+	api.HandleFunc("/semesters", s.semestersHandler).Methods("GET")
+	api.HandleFunc("/attendance", s.attendanceHandler).Methods("GET")
+	api.HandleFunc("/stats", s.statsHandler).Methods("GET")
+	api.HandleFunc("/final-grades", s.finalGradesHandler).Methods("GET")
+	api.HandleFunc("/classes", s.classesHandler).Methods("GET")
+	api.HandleFunc("/class", s.classHandler).Methods("POST")
+	api.HandleFunc("/calendar", s.calendarHandler).Methods("GET")
+	api.HandleFunc("/groups", s.groupsHandler).Methods("GET")
+	api.HandleFunc("/groups", s.pickGroupsHandler).Methods("POST")
+	api.HandleFunc("/diagnostics/disciplines", s.disciplinesDiagnosticsHandler).Methods("GET")
 
 	rootDir, err := fs2.Sub(ui.Build, "build")
 	if err != nil {
@@ -62,38 +145,26 @@ func BuildServer() (*mux.Router, error) {
 	})
 
 	return mux, nil
-	//err := http.ListenAndServe(fmt.Sprintf(":%d", &cfg.Port), mux)
-	//if err != nil {
-	//	fmt.Println("Error starting server:", err)
-	//}
 }
 
-func logoutHandler(writer http.ResponseWriter, request *http.Request) {
-	// Create a cookie with the same name but set to expire in the past
-	cookie := &http.Cookie{
-		Name:   "login_details",
-		Value:  "",  // Empty value
-		Path:   "/", // Must match the path of the cookie to delete it
-		MaxAge: -1,  // Expires immediately
-	}
-
-	// Set the cookie in the response to delete it
-	http.SetCookie(writer, cookie)
+func (s *server) logoutHandler(writer http.ResponseWriter, request *http.Request) {
+	s.sessions.clear(writer)
 	writer.WriteHeader(http.StatusOK)
 }
 
-func loggedInHandler(writer http.ResponseWriter, request *http.Request) {
-	c := loginCollector(writer, request)
+func (s *server) loggedInHandler(writer http.ResponseWriter, request *http.Request) {
+	c, sess := s.loginCollector(writer, request)
 	if c == nil {
 		return
 	}
 
 	respondWithJson(writer, &LoginResponse{
-		Name: c.StudentName,
+		Name:  c.StudentName,
+		Class: sess.ClassName(),
 	})
 }
 
-func loginHandler(writer http.ResponseWriter, request *http.Request) {
+func (s *server) loginHandler(writer http.ResponseWriter, request *http.Request) {
 	loginRequest := LoginRequest{}
 	err := json.NewDecoder(request.Body).Decode(&loginRequest)
 	if err != nil {
@@ -101,72 +172,385 @@ func loginHandler(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	c := collector.NewCollector()
+	c := collector.NewCollector(collector.WithBaseURL(s.diaryURL), collector.WithContext(request.Context()))
 
 	if err := c.Login(loginRequest.Username, loginRequest.Password); err != nil {
 		http.Error(writer, err.Error(), http.StatusForbidden)
 		return
 	}
 
-	cookieContent, err := json.Marshal(loginRequest)
-	if err != nil {
+	if err := s.sessions.set(writer, loginRequest.Username, loginRequest.Password, c); err != nil {
 		http.Error(writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Create a new cookie
-	cookie := &http.Cookie{
-		Name:  "login_details",
-		Value: base64.StdEncoding.EncodeToString(cookieContent),
-		Path:  "/",
-		// Optional settings
-		MaxAge:   3600,  // 1 hour
-		HttpOnly: true,  // Prevent JavaScript access
-		Secure:   false, // Set to true if using HTTPS
-	}
-
-	// Set the cookie in the response
-	http.SetCookie(writer, cookie)
-
 	respondWithJson(writer, &LoginResponse{
-		Name: c.StudentName,
+		Name:  c.StudentName,
+		Class: c.ClassName,
 	})
 }
 
-func lessonInfoHandler() (func(writer http.ResponseWriter, request *http.Request), error) {
-	scheduleDownloader, err := schedule.NewDownloader()
+func (s *server) classesHandler(writer http.ResponseWriter, request *http.Request) {
+	sched, err := s.scheduleDownloader.GetSchedule(request.Context())
 	if err != nil {
-		return nil, fmt.Errorf("creating schedule downloader: %w", err)
+		http.Error(writer, "could not download schedule: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return func(writer http.ResponseWriter, request *http.Request) {
-		ctx := context.Background()
+	respondWithJson(writer, schedule.ListClasses(sched))
+}
+
+// classHandler stores class picked by the user in the session, for students whose class is not detected correctly.
+func (s *server) classHandler(writer http.ResponseWriter, request *http.Request) {
+	sess, err := s.sessions.get(writer, request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	classRequest := ClassRequest{}
+	if err := json.NewDecoder(request.Body).Decode(&classRequest); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		c := loginCollector(writer, request)
-		if c == nil {
+	sess.Class = ""
+	// groups of one class mean nothing in another
+	sess.Groups = nil
+	if classRequest.Class != "" {
+		sched, err := s.scheduleDownloader.GetSchedule(request.Context())
+		if err != nil {
+			http.Error(writer, "could not download schedule: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		class, ok := schedule.FindClass(sched, classRequest.Class)
+		if !ok {
+			http.Error(writer, "unknown class "+classRequest.Class, http.StatusBadRequest)
 			return
 		}
+		sess.Class = class.Short
+	}
 
-		lessons, err := c.GetLessonInfos()
-		if err != nil {
-			http.Error(writer, err.Error(), http.StatusInternalServerError)
+	if err := s.sessions.write(writer, *sess); err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := LoginResponse{Class: sess.ClassName()}
+	if sess.Diary != nil {
+		response.Name = sess.Diary.StudentName
+	}
+	respondWithJson(writer, &response)
+}
+
+// calendarHandler lists non-teaching days of a school year: ?year=2024 for 2024-2025, current one by default.
+func (s *server) calendarHandler(writer http.ResponseWriter, request *http.Request) {
+	year := collector.SchoolYearOf(time.Now()).StartYear
+	if value := request.URL.Query().Get("year"); value != "" {
+		var err error
+		if year, err = strconv.Atoi(value); err != nil {
+			http.Error(writer, "invalid year "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
+	calendar, err := s.scheduleDownloader.SchoolYearCalendar(request.Context(), year)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJson(writer, calendar)
+}
+
+func (s *server) groupsHandler(writer http.ResponseWriter, request *http.Request) {
+	c, sess := s.loginCollector(writer, request)
+	if c == nil {
+		return
+	}
+
+	response, err := s.groups(request.Context(), c, sess)
+	s.updateSession(writer, sess, c)
+	if err != nil {
+		s.diaryError(writer, err)
+		return
+	}
+	respondWithJson(writer, response)
+}
+
+// pickGroupsHandler stores groups picked by the user in the session, for divisions that can't be inferred from the
+// diary, e.g. when both groups learn the same subject with different teachers.
+func (s *server) pickGroupsHandler(writer http.ResponseWriter, request *http.Request) {
+	c, sess := s.loginCollector(writer, request)
+	if c == nil {
+		return
+	}
+
+	groupsRequest := GroupsRequest{}
+	if err := json.NewDecoder(request.Body).Decode(&groupsRequest); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sched, err := s.scheduleDownloader.GetSchedule(request.Context())
+	if err != nil {
+		http.Error(writer, "could not download schedule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	known := lo.FlatMap(schedule.ClassDivisions(sched, sess.ClassName()), func(item schedule.ClassDivision, _ int) []string {
+		return groupNames(item.Groups)
+	})
+	for _, g := range groupsRequest.Groups {
+		if !slices.Contains(known, g) {
+			http.Error(writer, "unknown group "+g, http.StatusBadRequest)
 			return
 		}
+	}
+	sess.Groups = lo.Uniq(groupsRequest.Groups)
 
-		// enrich with timing data
-		sched, err := scheduleDownloader.GetSchedule(ctx)
+	response, err := s.groups(request.Context(), c, sess)
+	sess.Diary = lo.ToPtr(session.Diary(c.Session()))
+	if err := s.sessions.write(writer, *sess); err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		s.diaryError(writer, err)
+		return
+	}
+	respondWithJson(writer, response)
+}
+
+// groups lists divisions of student's class and groups student attends. Unless picked by the user, groups are inferred
+// from disciplines in the diary, which takes a diary request.
+func (s *server) groups(ctx context.Context, c *collector.Collector, sess *session.Session) (*GroupsResponse, error) {
+	sched, err := s.scheduleDownloader.GetSchedule(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not download schedule: %w", err)
+	}
+
+	className := sess.ClassName()
+	response := GroupsResponse{
+		Divisions: lo.Map(schedule.ClassDivisions(sched, className), func(item schedule.ClassDivision, _ int) []string {
+			return groupNames(item.Groups)
+		}),
+		Groups: sess.Groups,
+	}
+	if len(sess.Groups) == 0 && len(response.Divisions) > 0 {
+		disciplines, err := c.Disciplines()
 		if err != nil {
-			http.Error(writer, "could not download schedule: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
+		response.Groups = groupNames(schedule.InferGroups(sched, className, disciplines, s.disciplines))
+		response.Inferred = true
+	}
+	if response.Divisions == nil {
+		response.Divisions = [][]string{}
+	}
+	if response.Groups == nil {
+		response.Groups = []string{}
+	}
+	return &response, nil
+}
+
+// disciplinesDiagnosticsHandler reports how subjects of student's class are matched with diary disciplines of the
+// semester (current one unless ?semester= is given), to spot ones missing in the discipline mapping.
+func (s *server) disciplinesDiagnosticsHandler(writer http.ResponseWriter, request *http.Request) {
+	c, sess := s.loginCollector(writer, request)
+	if c == nil {
+		return
+	}
 
-		if err := enrichLessonsWithSchedule(lessons, sched); err != nil {
-			http.Error(writer, "failed to enrich lessons with schedule: "+err.Error(), http.StatusInternalServerError)
+	var opts []collector.LessonInfosOption
+	if semester := request.URL.Query().Get("semester"); semester != "" {
+		opts = append(opts, collector.WithSemester(semester))
+	}
+	disciplines, err := c.Disciplines(opts...)
+	s.updateSession(writer, sess, c)
+	if err != nil {
+		s.diaryError(writer, err)
+		return
+	}
+
+	sched, err := s.scheduleDownloader.GetSchedule(request.Context())
+	if err != nil {
+		http.Error(writer, "could not download schedule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJson(writer, s.disciplines.Report(schedule.ClassSubjects(sched, sess.ClassName()), disciplines))
+}
+
+func groupNames(groups []schedule.Group) []string {
+	return lo.Map(groups, func(item schedule.Group, _ int) string {
+		return item.Name
+	})
+}
+
+func (s *server) lessonInfoHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	infos, sess := s.collectLessonInfos(writer, request)
+	if infos == nil {
+		return
+	}
+	if len(infos.Failures) > 0 {
+		log.Printf("could not fetch details of %d lessons: %v", len(infos.Failures), errors.Join(lo.Map(infos.Failures, func(item collector.LessonFailure, _ int) error {
+			return fmt.Errorf("lesson %s: %w", item.LessonID, item.Err)
+		})...))
+	}
+	lessons := infos.Lessons
+	response := LessonInfoResponse{Lessons: lessons, FailedLessons: infos.Failures}
+	if response.FailedLessons == nil {
+		response.FailedLessons = []collector.LessonFailure{}
+	}
+
+	// without known class there is nothing to match lessons with; user can pick one with /api/class
+	className := sess.ClassName()
+	if className == "" {
+		respondWithoutSchedule(writer, response)
+		return
+	}
+
+	// enrich with timing data
+	now := time.Now()
+	from, to := scheduleRange(now)
+	schedules, err := s.scheduleDownloader.GetSchedules(ctx, from, to)
+	if err != nil {
+		http.Error(writer, "could not download schedule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	disciplines := lo.Uniq(lo.Map(lessons, func(item *collector.LessonInfo, _ int) string {
+		return item.Discipline
+	}))
+	groups := sess.Groups
+	if len(groups) == 0 {
+		groups = groupNames(schedule.InferGroups(schedules[len(schedules)-1], className, disciplines, s.disciplines))
+	}
+
+	calendar, err := s.scheduleDownloader.Calendar(ctx, from, to)
+	if err != nil {
+		http.Error(writer, "could not load calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dates, err := schedule.GetClassDatesAcross(className, schedules, from, to, schedule.WithGroups(groups), schedule.SkipHolidays(calendar))
+	if errors.Is(err, schedule.ErrClassNotFound) {
+		// e.g. class named differently in the diary; user can pick the right one with /api/class
+		log.Printf("lessons returned without schedule: %v", err)
+		respondWithoutSchedule(writer, response)
+		return
+	}
+	if err != nil {
+		http.Error(writer, "could not get class dates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// substitutions are nice to have; regular timetable is still useful without them
+	changes, err := s.scheduleDownloader.GetChangesAcross(ctx, schedules, className, from, to)
+	if err != nil {
+		log.Printf("could not download substitutions of %s: %v", className, err)
+	}
+	disciplineOf := s.subjectDisciplines(schedules, className, disciplines)
+	enrichLessonsWithSchedule(lessons, schedule.ApplyChanges(dates, changes), disciplineOf, now)
+
+	respondWithJson(writer, response)
+}
+
+// respondWithoutSchedule returns lessons that could not be matched with the timetable, with homework due dates guessed
+// from the text alone.
+func respondWithoutSchedule(writer http.ResponseWriter, response LessonInfoResponse) {
+	for _, l := range response.Lessons {
+		l.ResolveHomework(nil)
+	}
+	respondWithJson(writer, response)
+}
+
+// attendanceHandler counts absences of the semester (current one unless ?semester= is given) by discipline and week.
+func (s *server) attendanceHandler(writer http.ResponseWriter, request *http.Request) {
+	infos, _ := s.collectLessonInfos(writer, request, collector.WithoutDetails())
+	if infos == nil {
+		return
+	}
+	respondWithJson(writer, collector.SummarizeAttendance(infos.Lessons, infos.Absences))
+}
+
+// statsHandler sums up marks of the semester (current one unless ?semester= is given). ?weights= overrides
+// MARK_WEIGHTS, e.g. "test=2,homework=0.5".
+func (s *server) statsHandler(writer http.ResponseWriter, request *http.Request) {
+	weights := s.markWeights
+	if value := request.URL.Query().Get("weights"); value != "" {
+		var err error
+		if weights, err = collector.ParseMarkWeights(value); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
 			return
 		}
+	}
+
+	infos, _ := s.collectLessonInfos(writer, request, collector.WithoutDetails())
+	if infos == nil {
+		return
+	}
+	respondWithJson(writer, collector.ComputeStats(infos.Lessons, weights))
+}
+
+// finalGradesHandler returns semester, annual and exam grades of the school year of the semester (current one unless
+// ?semester= is given).
+func (s *server) finalGradesHandler(writer http.ResponseWriter, request *http.Request) {
+	c, sess := s.loginCollector(writer, request)
+	if c == nil {
+		return
+	}
+
+	var opts []collector.LessonInfosOption
+	if semester := request.URL.Query().Get("semester"); semester != "" {
+		opts = append(opts, collector.WithSemester(semester))
+	}
 
-		respondWithJson(writer, lessons)
-	}, nil
+	grades, err := c.GetFinalGrades(opts...)
+	s.updateSession(writer, sess, c)
+	if err != nil {
+		s.diaryError(writer, err)
+		return
+	}
+	respondWithJson(writer, grades)
+}
+
+// collectLessonInfos scrapes lessons of the semester requested by ?semester= query parameter, responding with an error
+// when that fails.
+func (s *server) collectLessonInfos(writer http.ResponseWriter, request *http.Request, opts ...collector.LessonInfosOption) (*collector.LessonInfos, *session.Session) {
+	c, sess := s.loginCollector(writer, request)
+	if c == nil {
+		return nil, nil
+	}
+
+	if semester := request.URL.Query().Get("semester"); semester != "" {
+		opts = append(opts, collector.WithSemester(semester))
+	}
+
+	infos, err := c.GetLessonInfos(opts...)
+	s.updateSession(writer, sess, c)
+	if err != nil {
+		s.diaryError(writer, err)
+		return nil, nil
+	}
+	s.markWeights.Resolve(infos.Lessons)
+	return infos, sess
+}
+
+func (s *server) semestersHandler(writer http.ResponseWriter, request *http.Request) {
+	c, sess := s.loginCollector(writer, request)
+	if c == nil {
+		return
+	}
+
+	semesters, err := c.ListSemesters()
+	s.updateSession(writer, sess, c)
+	if err != nil {
+		s.diaryError(writer, err)
+		return
+	}
+
+	respondWithJson(writer, semesters)
 }
 
 func respondWithJson(writer http.ResponseWriter, value any) {
@@ -179,50 +563,93 @@ func respondWithJson(writer http.ResponseWriter, value any) {
 	}
 }
 
-func loginCollector(writer http.ResponseWriter, request *http.Request) *collector.Collector {
-	c := collector.NewCollector()
-	loginCookie, err := request.Cookie("login_details")
+// loginCollector returns collector continuing diary session stored in user's session. Collector logs in to the diary
+// again by itself if that session has expired; call updateSession afterwards to store the new one.
+func (s *server) loginCollector(writer http.ResponseWriter, request *http.Request) (*collector.Collector, *session.Session) {
+	loginInfo, err := s.sessions.get(writer, request)
 	if err != nil {
 		http.Error(writer, err.Error(), http.StatusUnauthorized)
-		return nil
-	}
-	loginCookieValue, err := base64.StdEncoding.DecodeString(loginCookie.Value)
-	if err != nil {
-		http.Error(writer, err.Error(), http.StatusUnauthorized)
-		return nil
+		return nil, nil
 	}
 
-	loginInfo := LoginRequest{}
-	if err := json.Unmarshal(loginCookieValue, &loginInfo); err != nil {
-		http.Error(writer, err.Error(), http.StatusUnauthorized)
-		return nil
+	if loginInfo.Diary != nil {
+		c, err := collector.RestoreCollector(collector.UpstreamSession(*loginInfo.Diary), loginInfo.Username, loginInfo.Password, collector.WithBaseURL(s.diaryURL), collector.WithContext(request.Context()))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return nil, nil
+		}
+		return c, loginInfo
 	}
 
+	c := collector.NewCollector(collector.WithBaseURL(s.diaryURL), collector.WithContext(request.Context()))
 	if err := c.Login(loginInfo.Username, loginInfo.Password); err != nil {
 		http.Error(writer, err.Error(), http.StatusForbidden)
-		return nil
+		return nil, nil
 	}
 
-	return c
+	return c, loginInfo
 }
 
-func enrichLessonsWithSchedule(lessons []*collector.LessonInfo, s *schedule.Schedule) error {
-	now := time.Now()
+// updateSession stores diary session in the cookie if collector has logged in again. Failing that only costs an
+// extra login on the next request, so error is not propagated.
+func (s *server) updateSession(writer http.ResponseWriter, sess *session.Session, c *collector.Collector) {
+	if err := s.sessions.update(writer, sess, c); err != nil {
+		log.Printf("failed to update session: %v", err)
+	}
+}
+
+// diaryError responds with an error of a diary request. Expired diary sessions are renewed by the collector, so only
+// credentials no longer accepted by the diary end user's session, making the client ask to login again.
+func (s *server) diaryError(writer http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, collector.ErrLoginFailed):
+		s.sessions.clear(writer)
+		http.Error(writer, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, collector.ErrUnknownSemester):
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// scheduleRange is the period lesson dates are projected for: a month back, to match recent diary entries, and a week
+// ahead for upcoming lessons.
+func scheduleRange(now time.Time) (time.Time, time.Time) {
 	weekAhead := now.Add(time.Hour * 24 * 7)
 	monthBack := weekAhead.Add(-time.Hour * 24 * 30)
-	dates, err := schedule.GetClassDates("5d", s, monthBack, weekAhead)
-	if err != nil {
-		return fmt.Errorf("getting class dates: %w", err)
+	return monthBack, weekAhead
+}
+
+// subjectDisciplines maps names of the class subjects to diary disciplines they were matched with.
+func (s *server) subjectDisciplines(schedules []*schedule.Schedule, className string, disciplines []string) map[string]string {
+	result := map[string]string{}
+	for _, sched := range schedules {
+		for _, subject := range schedule.ClassSubjects(sched, className) {
+			match := s.disciplines.Match(subject, disciplines)
+			if match.Discipline != "" {
+				result[subject.Name] = match.Discipline
+			}
+		}
 	}
+	return result
+}
+
+// enrichLessonsWithSchedule sets timetable data of diary lessons. disciplineOf maps subject names to diary disciplines;
+// subjects missing there are taken to be named the same in the diary.
+func enrichLessonsWithSchedule(lessons []*collector.LessonInfo, dates []schedule.ClassDate, disciplineOf map[string]string, now time.Time) {
 	datesByDiscipline := lo.MapValues(lo.GroupBy(dates, func(item schedule.ClassDate) string {
-		return schedule.ToInternalName(item.Name)
+		if discipline, ok := disciplineOf[item.Name]; ok {
+			return discipline
+		}
+		return item.Name
 	}), func(item []schedule.ClassDate, _ string) schedule.ClassDate {
 		result := item[0]
 		for _, d := range item[1:] {
-			result.Dates = append(result.Dates, d.Dates...)
+			result.Occurrences = append(result.Occurrences, d.Occurrences...)
+			result.Changes = append(result.Changes, d.Changes...)
 		}
-		slices.SortFunc(result.Dates, func(a, b time.Time) int {
-			return a.Compare(b)
+		slices.SortFunc(result.Occurrences, func(a, b schedule.Occurrence) int {
+			return a.Start.Compare(b.Start)
 		})
 		return result
 	})
@@ -232,6 +659,7 @@ func enrichLessonsWithSchedule(lessons []*collector.LessonInfo, s *schedule.Sche
 		return item.Day.Format(time.DateOnly)
 	})
 
+	var unscheduled []string
 	for day, daysLessons := range lessonsByDay {
 		daysLessonsByDiscipline := lo.GroupBy(daysLessons, func(item *collector.LessonInfo) string {
 			return item.Discipline
@@ -240,31 +668,80 @@ func enrichLessonsWithSchedule(lessons []*collector.LessonInfo, s *schedule.Sche
 		for discipline, disciplineLessons := range daysLessonsByDiscipline {
 			disciplineInfo, ok := datesByDiscipline[discipline]
 			if !ok {
-				println("could not find discipline dates for", discipline)
+				unscheduled = append(unscheduled, discipline)
 				continue
 			}
 
-			nextDates := lo.Filter(disciplineInfo.Dates, func(item time.Time, _ int) bool {
-				return item.After(now)
+			nextLessons := lo.Filter(disciplineInfo.Occurrences, func(item schedule.Occurrence, _ int) bool {
+				return item.Start.After(now)
+			})
+			nextDates := lo.Map(nextLessons, func(item schedule.Occurrence, _ int) time.Time {
+				return item.Start
 			})
-			spew.Dump(discipline, nextDates)
 
 			for _, l := range disciplineLessons {
 				l.NextDates = nextDates
+				l.NextLessons = lo.Map(nextLessons, func(item schedule.Occurrence, _ int) collector.ScheduledLesson {
+					return scheduledLesson(item)
+				})
 			}
 
-			sameDayDisciplineDates := lo.Filter(disciplineInfo.Dates, func(item time.Time, _ int) bool {
-				return item.Format(time.DateOnly) == day
+			// cancelled lessons are left out of occurrences, but diary may still have them; their slots come from changes
+			slots := lo.Filter(disciplineInfo.Occurrences, func(item schedule.Occurrence, _ int) bool {
+				return item.Start.Format(time.DateOnly) == day
+			})
+			for _, change := range disciplineInfo.Changes {
+				if change.Cancelled && change.Start.Format(time.DateOnly) == day {
+					slots = append(slots, schedule.Occurrence{Start: change.Start, Period: change.Period})
+				}
+			}
+			// lessons outside of projected period keep their diary date
+			if len(slots) == 0 {
+				continue
+			}
+			slices.SortFunc(slots, func(a, b schedule.Occurrence) int {
+				return a.Start.Compare(b.Start)
 			})
 
-			// assign sameDayDisciplineDates to disciplineLessons date. ideally number of both should match
+			// assign slots to disciplineLessons in diary order. ideally number of both should match
+			slices.SortFunc(disciplineLessons, compareLessonIDs)
 			for index, l := range disciplineLessons {
-				adjustedDate := getItemOrLast(sameDayDisciplineDates, index)
-				l.Day = lo.ToPtr(adjustedDate)
+				slot := getItemOrLast(slots, index)
+				l.Day = lo.ToPtr(slot.Start)
+				change, _ := lo.Find(disciplineInfo.Changes, func(item schedule.Change) bool {
+					return item.Start.Equal(slot.Start)
+				})
+				l.Cancelled = change.Cancelled
+				l.SubstituteTeacher = change.SubstituteTeacher
+				if !change.Cancelled {
+					l.Scheduled = lo.ToPtr(scheduledLesson(slot))
+				}
 			}
 		}
 	}
 
+	if len(unscheduled) > 0 {
+		unscheduled = lo.Uniq(unscheduled)
+		slices.Sort(unscheduled)
+		log.Printf("no timetable lessons of disciplines %q", unscheduled)
+	}
+
+	// homework "for the next lesson" is due at the discipline's first class after the lesson day
+	for _, l := range lessons {
+		if len(l.Assignments) == 0 || l.Day == nil {
+			continue
+		}
+		day := l.Day.Format(time.DateOnly)
+		next, ok := lo.Find(datesByDiscipline[l.Discipline].Occurrences, func(item schedule.Occurrence) bool {
+			return item.Start.Format(time.DateOnly) > day
+		})
+		var nextLesson *time.Time
+		if ok {
+			nextLesson = lo.ToPtr(next.Start)
+		}
+		l.ResolveHomework(nextLesson)
+	}
+
 	slices.SortFunc(lessons, func(a, b *collector.LessonInfo) int {
 		if a.NextDates == nil {
 			if b.NextDates != nil {
@@ -279,12 +756,30 @@ func enrichLessonsWithSchedule(lessons []*collector.LessonInfo, s *schedule.Sche
 
 		return a.NextDates[0].Compare(b.NextDates[0])
 	})
-	return nil
 }
 
-func getItemOrLast(items []time.Time, index int) time.Time {
+// compareLessonIDs orders lessons as entered in the diary, which numbers them sequentially. Lessons without an ID,
+// known only from attendance, go last.
+func compareLessonIDs(a, b *collector.LessonInfo) int {
+	if (a.ID == "") != (b.ID == "") {
+		if a.ID == "" {
+			return 1
+		}
+		return -1
+	}
+	if len(a.ID) != len(b.ID) {
+		return len(a.ID) - len(b.ID)
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+func getItemOrLast[T any](items []T, index int) T {
 	if len(items) > index {
 		return items[index]
 	}
 	return items[len(items)-1]
 }
+
+func scheduledLesson(o schedule.Occurrence) collector.ScheduledLesson {
+	return collector.ScheduledLesson{Start: o.Start, End: o.End, Period: o.Period, Room: o.Room, Teacher: o.Teacher}
+}
@@ -4,8 +4,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
+
+	"vjgdienynas/collector"
+	"vjgdienynas/schedule"
 )
 
 func TestServer(t *testing.T) {
@@ -32,3 +37,72 @@ func TestServer(t *testing.T) {
 	r.Equal(http.StatusOK, resp.Code)
 
 }
+
+func TestServer_RejectsLegacyLoginCookie(t *testing.T) {
+	r := require.New(t)
+	s, err := BuildServer()
+	r.NoError(err)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/lesson-info", nil)
+	req.AddCookie(&http.Cookie{Name: legacyCookieName, Value: "eyJ1c2VybmFtZSI6InVzZXIiLCJwYXNzd29yZCI6InNlY3JldCJ9"})
+	s.ServeHTTP(resp, req)
+
+	r.Equal(http.StatusUnauthorized, resp.Code)
+	cleared := resp.Result().Cookies()
+	r.Len(cleared, 1)
+	r.Equal(legacyCookieName, cleared[0].Name)
+	r.Equal(-1, cleared[0].MaxAge)
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/login", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "v1.garbage"})
+	s.ServeHTTP(resp, req)
+
+	r.Equal(http.StatusUnauthorized, resp.Code)
+}
+
+func TestEnrichLessonsWithSchedule(t *testing.T) {
+	r := require.New(t)
+	at := func(day int, hour int) time.Time {
+		return time.Date(2024, time.October, day, hour, 0, 0, 0, time.UTC)
+	}
+	occurrence := func(day int, hour int, period string) schedule.Occurrence {
+		return schedule.Occurrence{Start: at(day, hour), End: at(day, hour).Add(45 * time.Minute), Period: period, Room: "101", Teacher: "Petras Petraitis"}
+	}
+	occurrences := []schedule.Occurrence{occurrence(8, 8, "1"), occurrence(8, 10, "3"), occurrence(9, 8, "1"), occurrence(15, 8, "1")}
+	dates := []schedule.ClassDate{{
+		Name: "Mat",
+		Dates: lo.Map(occurrences, func(item schedule.Occurrence, _ int) time.Time {
+			return item.Start
+		}),
+		Occurrences: occurrences,
+	}}
+	dates = schedule.ApplyChanges(dates, []schedule.Change{
+		{Subject: "Mat", Start: at(8, 8), Period: "1", Cancelled: true},
+		{Subject: "Mat", Start: at(9, 8), Period: "1", SubstituteTeacher: "Ona Onaitienė", Room: "202"},
+	})
+
+	lessons := []*collector.LessonInfo{
+		{ID: "1010", Discipline: "Matematika", Day: lo.ToPtr(at(8, 0))},
+		{ID: "999", Discipline: "Matematika", Day: lo.ToPtr(at(8, 0))},
+		{ID: "1011", Discipline: "Matematika", Day: lo.ToPtr(at(9, 0))},
+	}
+	enrichLessonsWithSchedule(lessons, dates, map[string]string{"Mat": "Matematika"}, at(10, 0))
+	byID := lo.KeyBy(lessons, func(item *collector.LessonInfo) string {
+		return item.ID
+	})
+
+	// first lesson of the day was cancelled, the diary one entered first is that one
+	r.True(byID["999"].Cancelled)
+	r.Equal(at(8, 8), *byID["999"].Day)
+	r.Nil(byID["999"].Scheduled)
+	r.False(byID["1010"].Cancelled)
+	r.Equal(at(8, 10), *byID["1010"].Day)
+	r.Equal("3", byID["1010"].Scheduled.Period)
+
+	substituted := byID["1011"]
+	r.Equal("Ona Onaitienė", substituted.SubstituteTeacher)
+	r.Equal("202", substituted.Scheduled.Room)
+	r.Equal([]time.Time{at(15, 8)}, substituted.NextDates)
+}
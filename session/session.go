@@ -0,0 +1,164 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KeySize is the required length of session encryption keys (AES-256).
+const KeySize = 32
+
+// version prefixes every encoded session; cookies without it (e.g. legacy plaintext ones) are rejected.
+const version = "v1"
+
+var (
+	ErrInvalid = errors.New("invalid session")
+	ErrExpired = errors.New("session expired")
+)
+
+// Session is login state kept on the client side in an encrypted cookie.
+type Session struct {
+	Username  string    `json:"u"`
+	Password  string    `json:"p"`
+	ExpiresAt time.Time `json:"exp"`
+	// Diary is upstream diary session, reused until it expires there
+	Diary *Diary `json:"d,omitempty"`
+	// Class is timetable class picked by the user, overriding the one detected in the diary
+	Class string `json:"c,omitempty"`
+	// Groups are names of timetable groups picked by the user (e.g. language groups); when empty, groups are inferred
+	// from disciplines in the diary
+	Groups []string `json:"g,omitempty"`
+}
+
+// Diary is diary login state, convertible to and from collector.UpstreamSession.
+type Diary struct {
+	Token       string            `json:"token"`
+	StudentName string            `json:"studentName"`
+	ClassName   string            `json:"className,omitempty"`
+	Cookies     map[string]string `json:"cookies,omitempty"`
+}
+
+// ClassName returns student's class: picked by the user, or else detected in the diary.
+func (s Session) ClassName() string {
+	if s.Class != "" {
+		return s.Class
+	}
+	if s.Diary != nil {
+		return s.Diary.ClassName
+	}
+	return ""
+}
+
+// Codec encrypts and authenticates sessions with AES-GCM. First key is used for encryption, all keys are accepted
+// for decryption, so keys can be rotated by prepending a new one and dropping the oldest once sessions expire.
+type Codec struct {
+	aeads []cipher.AEAD
+	now   func() time.Time
+}
+
+func NewCodec(keys [][]byte) (*Codec, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+
+	c := &Codec{now: time.Now}
+	for i, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %d: expected %d bytes, got %d", i, KeySize, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+// ParseKeys parses comma separated list of base64 encoded keys, newest first.
+func ParseKeys(value string) ([][]byte, error) {
+	var result [][]byte
+	for i, k := range strings.Split(value, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %d: %w", i, err)
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// GenerateKey returns a new random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encode encrypts session into a cookie-safe string.
+func (c *Codec) Encode(s Session) (string, error) {
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	aead := c.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(version))
+	return version + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decode decrypts and validates a value produced by Encode. Tampered, unknown format or expired values are rejected
+// with ErrInvalid or ErrExpired.
+func (c *Codec) Decode(value string) (*Session, error) {
+	payload, ok := strings.CutPrefix(value, version+".")
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported format", ErrInvalid)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	for _, aead := range c.aeads {
+		if len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("%w: too short", ErrInvalid)
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(version))
+		if err != nil {
+			continue
+		}
+
+		s := Session{}
+		if err := json.Unmarshal(plaintext, &s); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		if !c.now().Before(s.ExpiresAt) {
+			return nil, ErrExpired
+		}
+		return &s, nil
+	}
+
+	return nil, fmt.Errorf("%w: could not decrypt", ErrInvalid)
+}
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCodec(t *testing.T, keys ...[]byte) *Codec {
+	t.Helper()
+	c, err := NewCodec(keys)
+	require.NoError(t, err)
+	return c
+}
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	return key
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	r := require.New(t)
+	c := newTestCodec(t, newTestKey(t))
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	diary := &Diary{Token: "secret", StudentName: "Jonas Jonaitis", Cookies: map[string]string{"PHPSESSID": "sess1"}}
+	value, err := c.Encode(Session{Username: "user", Password: "secret", ExpiresAt: expires, Diary: diary})
+	r.NoError(err)
+	r.NotContains(value, "secret")
+
+	s, err := c.Decode(value)
+	r.NoError(err)
+	r.Equal("user", s.Username)
+	r.Equal("secret", s.Password)
+	r.Equal(diary, s.Diary)
+	r.True(expires.Equal(s.ExpiresAt))
+}
+
+func TestCodec_Rejects(t *testing.T) {
+	key := newTestKey(t)
+	c := newTestCodec(t, key)
+	valid, err := c.Encode(Session{Username: "user", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	expired, err := c.Encode(Session{Username: "user", ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	legacy, err := json.Marshal(map[string]string{"username": "user", "password": "secret"})
+	require.NoError(t, err)
+	otherKey, err := newTestCodec(t, newTestKey(t)).Encode(Session{Username: "user", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		value    string
+		expected error
+	}{
+		"legacy cookie": {value: base64.StdEncoding.EncodeToString(legacy), expected: ErrInvalid},
+		"tampered":      {value: tamper(valid), expected: ErrInvalid},
+		"truncated":     {value: "v1.AAAA", expected: ErrInvalid},
+		"unknown key":   {value: otherKey, expected: ErrInvalid},
+		"expired":       {value: expired, expected: ErrExpired},
+	}
+
+	for testName, tt := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := c.Decode(tt.value)
+			require.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestCodec_KeyRotation(t *testing.T) {
+	r := require.New(t)
+	oldKey := newTestKey(t)
+	newKey := newTestKey(t)
+
+	oldValue, err := newTestCodec(t, oldKey).Encode(Session{Username: "user", ExpiresAt: time.Now().Add(time.Hour)})
+	r.NoError(err)
+
+	rotated := newTestCodec(t, newKey, oldKey)
+	s, err := rotated.Decode(oldValue)
+	r.NoError(err)
+	r.Equal("user", s.Username)
+
+	newValue, err := rotated.Encode(*s)
+	r.NoError(err)
+	_, err = newTestCodec(t, oldKey).Decode(newValue)
+	r.ErrorIs(err, ErrInvalid)
+}
+
+func TestParseKeys(t *testing.T) {
+	r := require.New(t)
+	key := newTestKey(t)
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	keys, err := ParseKeys(encoded + ", " + encoded + ",")
+	r.NoError(err)
+	r.Len(keys, 2)
+	r.Equal(key, keys[0])
+
+	_, err = ParseKeys("not base64!")
+	r.Error(err)
+
+	_, err = NewCodec([][]byte{[]byte("short")})
+	r.Error(err)
+}
+
+// tamper flips one character in the encrypted part of the value
+func tamper(value string) string {
+	b := []byte(value)
+	i := len(b) / 2
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	return string(b)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/samber/lo"
+
+	"vjgdienynas/collector"
+	"vjgdienynas/session"
+)
+
+const sessionCookieName = "session"
+
+// legacyCookieName is the cookie that used to hold plaintext credentials. It is no longer accepted, only cleared.
+const legacyCookieName = "login_details"
+
+const sessionDuration = time.Hour
+
+var errNotLoggedIn = errors.New("not logged in")
+
+type sessionCookies struct {
+	codec *session.Codec
+	// secure marks cookies as HTTPS-only; disabled for local development over plain HTTP
+	secure bool
+}
+
+// newSessionCookies configures session encryption from SESSION_KEYS: comma separated base64 encoded 32 byte keys,
+// newest first. Without configured keys (Lambda tests, local runs) a random one is generated, and sessions will not
+// survive a restart; standalone mode refuses to start without them.
+func newSessionCookies() (*sessionCookies, error) {
+	keys, err := session.ParseKeys(os.Getenv("SESSION_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing SESSION_KEYS: %w", err)
+	}
+	if len(keys) == 0 {
+		log.Print("SESSION_KEYS not set, using temporary session key")
+		key, err := session.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("generating session key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	codec, err := session.NewCodec(keys)
+	if err != nil {
+		return nil, fmt.Errorf("creating session codec: %w", err)
+	}
+
+	return &sessionCookies{
+		codec:  codec,
+		secure: os.Getenv("SESSION_COOKIE_INSECURE") != "true",
+	}, nil
+}
+
+// set stores a new session for logged in collector.
+func (s *sessionCookies) set(writer http.ResponseWriter, username string, password string, c *collector.Collector) error {
+	return s.write(writer, session.Session{
+		Username:  username,
+		Password:  password,
+		ExpiresAt: time.Now().Add(sessionDuration),
+		Diary:     lo.ToPtr(session.Diary(c.Session())),
+	})
+}
+
+// update rewrites session cookie if collector had to login to diary again, keeping original session expiry.
+// Must be called before response body is written.
+func (s *sessionCookies) update(writer http.ResponseWriter, current *session.Session, c *collector.Collector) error {
+	diary := session.Diary(c.Session())
+	if current.Diary != nil && reflect.DeepEqual(*current.Diary, diary) {
+		return nil
+	}
+
+	updated := *current
+	updated.Diary = &diary
+	return s.write(writer, updated)
+}
+
+func (s *sessionCookies) write(writer http.ResponseWriter, value session.Session) error {
+	encoded, err := s.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+
+	maxAge := max(1, int(time.Until(value.ExpiresAt).Seconds()))
+	http.SetCookie(writer, s.cookie(sessionCookieName, encoded, maxAge))
+	return nil
+}
+
+// get returns session from request cookies. Missing, legacy, tampered or expired cookies are cleared from the client.
+func (s *sessionCookies) get(writer http.ResponseWriter, request *http.Request) (*session.Session, error) {
+	if _, err := request.Cookie(legacyCookieName); err == nil {
+		http.SetCookie(writer, s.cookie(legacyCookieName, "", -1))
+	}
+
+	cookie, err := request.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, errNotLoggedIn
+	}
+
+	result, err := s.codec.Decode(cookie.Value)
+	if err != nil {
+		http.SetCookie(writer, s.cookie(sessionCookieName, "", -1))
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *sessionCookies) clear(writer http.ResponseWriter) {
+	http.SetCookie(writer, s.cookie(sessionCookieName, "", -1))
+	http.SetCookie(writer, s.cookie(legacyCookieName, "", -1))
+}
+
+func (s *sessionCookies) cookie(name string, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -4,57 +4,42 @@
 package testdata
 
 import (
-	"bytes"
-	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"vjgdienynas/capture"
 	"vjgdienynas/collector"
 )
 
+// TestDownloadData logs into the real diary, records the whole session and stores it anonymized in data/session.json,
+// ready to be replayed with capture.Replayer. Review the result before committing: only names of the student, their
+// class and teachers are known to the anonymizer, other people mentioned in notes or topics are not.
 func TestDownloadData(t *testing.T) {
-	t.Skip("unfinished. need to think about storing returned cookies etc.")
-
-	c := collector.NewCollector()
-	c.WithTransport(&loggingTransport{http.DefaultTransport})
-
 	r := require.New(t)
-	_ = os.RemoveAll("data")
-	r.NoError(os.MkdirAll("data", 0755))
-	r.NoError(c.Login(os.Getenv("E2E_USER"), os.Getenv((os.Getenv("E2E_PASSWORD")))))
-}
-
-type loggingTransport struct {
-	rt http.RoundTripper
-}
-
-func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Call the original RoundTripper (which performs the request)
-	resp, err := t.rt.RoundTrip(req)
-	if err != nil {
-		return nil, err
+	if os.Getenv("E2E_USER") == "" {
+		t.Skip("E2E_USER and E2E_PASSWORD are needed to download test data")
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-	resp.Body = io.NopCloser(bytes.NewBuffer(body)) // Reset response body after reading
-
-	if err := os.WriteFile(convertURLToFilename(req.URL), body, 0644); err != nil {
-		return nil, fmt.Errorf("writing file: %w", err)
-	}
+	recorder := capture.NewRecorder(http.DefaultTransport)
+	c := collector.NewCollector()
+	c.WithTransport(recorder)
 
-	return resp, nil
-}
+	r.NoError(c.Login(os.Getenv("E2E_USER"), os.Getenv("E2E_PASSWORD")))
+	_, err := c.ListSemesters()
+	r.NoError(err)
+	infos, err := c.GetLessonInfos()
+	r.NoError(err)
+	// final grades view is recorded too, as fixture for fakediary final.html
+	_, err = c.GetFinalGrades()
+	r.NoError(err)
 
-var urlToFileName = regexp.MustCompile(`[^a-zA-Z0-9-_]`)
+	a := capture.NewAnonymizer()
+	a.AddCollected(c, infos.Lessons)
 
-func convertURLToFilename(url *url.URL) string {
-	return filepath.Join("data", strings.TrimSpace(urlToFileName.ReplaceAllString(url.String(), "_")))
+	r.NoError(os.MkdirAll("data", 0755))
+	r.NoError(a.Anonymize(recorder.Recording()).Write(filepath.Join("data", "session.json")))
 }